@@ -10,6 +10,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	watchdog "github.com/cloudflare/tableflip"
@@ -26,9 +27,15 @@ import (
 	"git.backbone/corpix/goboilerplate/pkg/log"
 	"git.backbone/corpix/goboilerplate/pkg/meta"
 	"git.backbone/corpix/goboilerplate/pkg/reflect"
+	"git.backbone/corpix/goboilerplate/pkg/server"
 	"git.backbone/corpix/goboilerplate/pkg/server/csrf"
 	"git.backbone/corpix/goboilerplate/pkg/server/session"
 	"git.backbone/corpix/goboilerplate/pkg/telemetry"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/debug"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/health"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/push"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/statsd"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/trace"
 )
 
 var (
@@ -64,11 +71,44 @@ var (
 		},
 		&cli.BoolFlag{
 			Name:  "profile",
-			Usage: "write profile information for debugging (cpu.prof, heap.prof)",
+			Usage: "write profile information for debugging on exit (cpu, heap and enabled block/mutex profiles)",
+		},
+		&cli.StringFlag{
+			Name:  "profile-cpu-path",
+			Usage: "path to write cpu profile into",
+			Value: "cpu.prof",
+		},
+		&cli.StringFlag{
+			Name:  "profile-heap-path",
+			Usage: "path to write heap profile into",
+			Value: "heap.prof",
+		},
+		&cli.StringFlag{
+			Name:  "profile-block-path",
+			Usage: "path to write block profile into (written when block-profile-rate is set)",
+			Value: "block.prof",
+		},
+		&cli.StringFlag{
+			Name:  "profile-mutex-path",
+			Usage: "path to write mutex profile into (written when mutex-profile-fraction is set)",
+			Value: "mutex.prof",
+		},
+		&cli.IntFlag{
+			Name:  "block-profile-rate",
+			Usage: "block profile rate (see runtime.SetBlockProfileRate), 0 disables block profiling",
+		},
+		&cli.IntFlag{
+			Name:  "mutex-profile-fraction",
+			Usage: "mutex profile fraction (see runtime.SetMutexProfileFraction), 0 disables mutex profiling",
 		},
 		&cli.BoolFlag{
 			Name:  "trace",
-			Usage: "write trace information for debugging (trace.prof)",
+			Usage: "write trace information for debugging on exit",
+		},
+		&cli.StringFlag{
+			Name:  "trace-path",
+			Usage: "path to write trace into",
+			Value: "trace.prof",
 		},
 	}
 	Commands = []*cli.Command{
@@ -301,6 +341,26 @@ var (
 				},
 			},
 		},
+		{
+			Name:    "telemetry",
+			Aliases: []string{"t"},
+			Usage:   "Telemetry tools",
+			Subcommands: []*cli.Command{
+				{
+					Name:    "metrics",
+					Aliases: []string{"m"},
+					Usage:   "List metrics registered by configured components",
+					Action:  TelemetryMetricsAction,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:    "json",
+							Aliases: []string{"j"},
+							Usage:   "use json format",
+						},
+					},
+				},
+			},
+		},
 	}
 
 	c *di.Container
@@ -374,6 +434,51 @@ func Before(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	err = c.Provide(func(c *config.Config, r *telemetry.Registry) *health.Health {
+		return health.New(
+			*c.Telemetry.Health,
+			health.WithMetrics(health.NewMetrics(r, telemetry.Subsystem)),
+		)
+	})
+	if err != nil {
+		return err
+	}
+	err = c.Provide(func(
+		c *config.Config,
+		l log.Logger,
+		r *telemetry.Registry,
+		rand crypto.Rand,
+		w *watchdog.Upgrader,
+		running *sync.WaitGroup,
+	) *trace.Tracer {
+		if !c.Telemetry.Trace.Enable {
+			return nil
+		}
+
+		t := trace.New(
+			*c.Telemetry.Trace, l, rand,
+			trace.WithMetrics(trace.NewMetrics(r, telemetry.Subsystem)),
+		)
+
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			<-w.Exit()
+
+			ctx, cancel := context.WithTimeout(context.Background(), c.Telemetry.Trace.Timeout)
+			defer cancel()
+
+			err := t.Shutdown(ctx)
+			if err != nil {
+				l.Error().Err(err).Msg("trace shutdown failed")
+			}
+		}()
+
+		return t
+	})
+	if err != nil {
+		return err
+	}
 
 	//
 
@@ -381,6 +486,7 @@ func Before(ctx *cli.Context) error {
 		c *config.Config,
 		l log.Logger,
 		r *telemetry.Registry,
+		h *health.Health,
 		w *watchdog.Upgrader,
 		running *sync.WaitGroup,
 		errc chan error,
@@ -407,15 +513,27 @@ func Before(ctx *cli.Context) error {
 			if err != nil {
 				return nil, err
 			}
-			t, err := telemetry.New(*c.Telemetry, l, r, lr)
+			lr, err = server.NewListener(*c.Telemetry.HTTP, lr)
+			if err != nil {
+				return nil, err
+			}
+			t, err := telemetry.New(*c.Telemetry, l, r, h, lr)
 			if err != nil {
 				return nil, err
 			}
+			if c.Telemetry.Debug.Enable {
+				d, err := debug.New(*c.Telemetry.Debug, debug.WithConfigDump(c))
+				if err != nil {
+					return nil, err
+				}
+				t.MountDebug(d)
+			}
 
 			running.Add(1)
 
 			go start(t)
 			go finalize(t)
+			go t.WatchTLS(w.Exit())
 
 			return t, nil
 		}
@@ -426,6 +544,62 @@ func Before(ctx *cli.Context) error {
 		return err
 	}
 
+	err = c.Provide(func(
+		c *config.Config,
+		l log.Logger,
+		r *telemetry.Registry,
+		w *watchdog.Upgrader,
+		running *sync.WaitGroup,
+	) *push.Pusher {
+		if !c.Telemetry.Push.Enable {
+			return nil
+		}
+
+		p := push.New(
+			*c.Telemetry.Push, l, r,
+			push.WithMetrics(push.NewMetrics(r, telemetry.Subsystem)),
+		)
+
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			p.Run(w.Exit()) // pushes last time on exit, so short-living jobs are not lost
+		}()
+
+		return p
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Provide(func(
+		c *config.Config,
+		l log.Logger,
+		r *telemetry.Registry,
+		w *watchdog.Upgrader,
+		running *sync.WaitGroup,
+	) (*statsd.Exporter, error) {
+		if !c.Telemetry.StatsD.Enable {
+			return nil, nil
+		}
+
+		e, err := statsd.New(*c.Telemetry.StatsD, l, r)
+		if err != nil {
+			return nil, err
+		}
+
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			e.Run(w.Exit())
+		}()
+
+		return e, nil
+	})
+	if err != nil {
+		return err
+	}
+
 	//
 
 	err = c.Provide(func(ctx *cli.Context, c *config.Config) (*watchdog.Upgrader, error) {
@@ -488,6 +662,10 @@ func Before(ctx *cli.Context) error {
 
 	//
 
+	// NOTE: rates are set regardless of profile flag,
+	// so block and mutex profiles are available from debug endpoints too
+	setProfileRates(ctx)
+
 	if ctx.Bool("profile") {
 		err = c.Invoke(writeProfile)
 		if err != nil {
@@ -801,6 +979,54 @@ func ServerCSRFValidateAction(ctx *cli.Context) error {
 
 //
 
+func TelemetryMetricsAction(ctx *cli.Context) error {
+	return c.Invoke(func(
+		c *config.Config,
+		l log.Logger,
+		r *telemetry.Registry,
+		h *health.Health,
+		enc *json.Encoder,
+	) error {
+		// components register their metrics on construction,
+		// listener is not required to build telemetry server
+		if c.Telemetry.Enable {
+			_, err := telemetry.New(*c.Telemetry, l, r, h, nil)
+			if err != nil {
+				return err
+			}
+		}
+		if c.Telemetry.Trace.Enable {
+			trace.NewMetrics(r, telemetry.Subsystem)
+		}
+		if c.Telemetry.Push.Enable {
+			push.NewMetrics(r, telemetry.Subsystem)
+		}
+
+		descriptors, err := r.Descriptors()
+		if err != nil {
+			return err
+		}
+
+		if ctx.Bool("json") {
+			err = enc.Encode(descriptors)
+			if err != nil {
+				return err
+			}
+			os.Stdout.Write([]byte("\n"))
+			return nil
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tKIND\tLABELS\tHELP")
+		for _, d := range descriptors {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Name, d.Kind, strings.Join(d.Labels, ","), d.Help)
+		}
+		return tw.Flush()
+	})
+}
+
+//
+
 func RootAction(ctx *cli.Context) error {
 	components := c.String()
 	_ = c.Invoke(func(l log.Logger) {
@@ -816,12 +1042,33 @@ func RootAction(ctx *cli.Context) error {
 		w *watchdog.Upgrader,
 		l log.Logger,
 		t *telemetry.Server,
+		_ *push.Pusher, // requested to start pushing
+		_ *statsd.Exporter,
+		_ *trace.Tracer, // requested to start exporting, applications pass it to server.New
+		h *health.Health,
 		running *sync.WaitGroup,
 		errc chan error,
 		sig chan os.Signal,
 	) error {
 		l.Info().Msg("running")
 
+		// readiness fails shutdown-delay before components are stopped,
+		// so probes notice it and traffic is drained from the instance,
+		// repeated signal stops without waiting
+		stop := func() {
+			h.Shutdown()
+			if delay := h.ShutdownDelay(); delay > 0 {
+				l.Info().Dur("delay", delay).Msg("draining before shutdown")
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-sig:
+					timer.Stop()
+				}
+			}
+			w.Stop()
+		}
+
 		err := w.Ready()
 		if err != nil {
 			return err
@@ -833,9 +1080,10 @@ func RootAction(ctx *cli.Context) error {
 		for {
 			select {
 			case <-w.Exit():
+				h.Shutdown()
 				break loop
 			case <-ctx.Done():
-				w.Stop()
+				stop()
 				break loop
 
 			case err := <-errc:
@@ -846,14 +1094,26 @@ func RootAction(ctx *cli.Context) error {
 				l.Info().Str("signal", si.String()).Msg("received signal")
 				switch si {
 				case syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
-					w.Stop()
+					stop()
 				case syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP:
 					err = w.Upgrade()
 					if err != nil {
 						return err
 					}
 				}
-			case <-bus.Config:
+			case u := <-bus.Config:
+				if u.Subsystem == server.TLSSubsystem {
+					// certificates are swapped in place, no need to upgrade
+					if t == nil {
+						continue
+					}
+					cc, _ := u.Config.(*server.TLSConfig)
+					err = t.ReloadTLS(cc)
+					if err != nil {
+						l.Error().Err(err).Msg("failed to reload tls certificates")
+					}
+					continue
+				}
 				err = w.Upgrade()
 				if err != nil {
 					return err
@@ -3,67 +3,114 @@ package cli
 import (
 	"context"
 	"os"
+	"runtime"
 	"runtime/pprof"
 	"runtime/trace"
+	"sync"
+
+	watchdog "github.com/cloudflare/tableflip"
+	cli "github.com/urfave/cli/v2"
 
 	"git.backbone/corpix/goboilerplate/pkg/log"
 )
 
-// writeProfile writes cpu and heap profile into files.
-func writeProfile(ctx context.Context, l log.Logger) error {
-	cpu, err := os.Create("cpu.prof")
+// setProfileRates enables block and mutex profiling.
+func setProfileRates(ctx *cli.Context) {
+	if rate := ctx.Int("block-profile-rate"); rate > 0 {
+		runtime.SetBlockProfileRate(rate)
+	}
+	if fraction := ctx.Int("mutex-profile-fraction"); fraction > 0 {
+		runtime.SetMutexProfileFraction(fraction)
+	}
+}
+
+func writeNamedProfile(l log.Logger, name string, path string) {
+	f, err := os.Create(path)
 	if err != nil {
-		return err
+		l.Error().Err(err).Str("path", path).Msgf("failed to create %s profile", name)
+		return
 	}
-	heap, err := os.Create("heap.prof")
+	defer f.Close()
+
+	err = pprof.Lookup(name).WriteTo(f, 0)
+	if err != nil {
+		l.Error().Err(err).Str("path", path).Msgf("failed to write %s profile", name)
+	}
+}
+
+// writeProfile writes cpu and heap profile (and block, mutex profiles when enabled) into files
+// when context is done or application is shutting down.
+func writeProfile(
+	cctx *cli.Context,
+	ctx context.Context,
+	l log.Logger,
+	w *watchdog.Upgrader,
+	running *sync.WaitGroup,
+) error {
+	cpu, err := os.Create(cctx.String("profile-cpu-path"))
 	if err != nil {
 		return err
 	}
 
 	err = pprof.StartCPUProfile(cpu)
 	if err != nil {
+		cpu.Close()
 		return err
 	}
 
+	running.Add(1)
 	go func() {
+		defer running.Done()
 		defer cpu.Close()
-		defer heap.Close()
 
-		<-ctx.Done()
+		select {
+		case <-ctx.Done():
+		case <-w.Exit():
+		}
 
 		pprof.StopCPUProfile()
-		err := pprof.WriteHeapProfile(heap)
-		if err != nil {
-			l.Error().Err(err).Msg("failed to write heap profile")
-			os.Exit(1)
-			return
+		writeNamedProfile(l, "heap", cctx.String("profile-heap-path"))
+		if cctx.Int("block-profile-rate") > 0 {
+			writeNamedProfile(l, "block", cctx.String("profile-block-path"))
+		}
+		if cctx.Int("mutex-profile-fraction") > 0 {
+			writeNamedProfile(l, "mutex", cctx.String("profile-mutex-path"))
 		}
-
-		os.Exit(0)
 	}()
 
 	return nil
 }
 
-// writeTrace writes tracing data to file.
-func writeTrace(ctx context.Context, l log.Logger) error {
-	t, err := os.Create("trace.prof")
+// writeTrace writes tracing data to file
+// when context is done or application is shutting down.
+func writeTrace(
+	cctx *cli.Context,
+	ctx context.Context,
+	w *watchdog.Upgrader,
+	running *sync.WaitGroup,
+) error {
+	t, err := os.Create(cctx.String("trace-path"))
 	if err != nil {
 		return err
 	}
 
 	err = trace.Start(t)
 	if err != nil {
+		t.Close()
 		return err
 	}
 
+	running.Add(1)
 	go func() {
+		defer running.Done()
 		defer t.Close()
 
-		<-ctx.Done()
+		select {
+		case <-ctx.Done():
+		case <-w.Exit():
+		}
 
 		trace.Stop()
-		os.Exit(0)
 	}()
 
 	return nil
@@ -15,6 +15,8 @@ require (
 	github.com/mattn/go-colorable v0.1.12 // indirect
 	github.com/mattn/go-isatty v0.0.14
 	github.com/prometheus/client_golang v1.10.0
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/common v0.18.0
 	github.com/rs/zerolog v1.22.0
 	github.com/swaggo/echo-swagger v1.1.0
 	github.com/swaggo/swag v1.7.0
@@ -22,7 +24,8 @@ require (
 	github.com/vmihailenco/msgpack/v5 v5.3.4
 	go.uber.org/dig v1.10.0
 	golang.org/x/crypto v0.0.0-20211202192323-5770296d904e
-	golang.org/x/net v0.0.0-20211207213349-853792941377 // indirect
+	golang.org/x/net v0.0.0-20211207213349-853792941377
 	golang.org/x/sys v0.0.0-20211205182925-97ca703d548d // indirect
+	gopkg.in/yaml.v2 v2.4.0
 	lukechampine.com/blake3 v1.1.5
 )
@@ -135,12 +135,24 @@ let
                 inherit limits;
                 requests = limits;
               };
-              # livenessProbe.httpGet = {
-              #   path = "/ping";
-              #   port = name;
-              # };
-
-              # readinessProbe = livenessProbe;
+              # NOTE: probes are served by telemetry server,
+              # readiness fails as soon as shutdown begins
+              livenessProbe = {
+                httpGet = {
+                  path = "/livez";
+                  port = "metrics";
+                };
+                periodSeconds = 10;
+                failureThreshold = 3;
+              };
+              readinessProbe = {
+                httpGet = {
+                  path = "/readyz";
+                  port = "metrics";
+                };
+                periodSeconds = 5;
+                failureThreshold = 1;
+              };
             };
           };
         };
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"net/url"
 	"strings"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"git.backbone/corpix/goboilerplate/pkg/bus"
 	"git.backbone/corpix/goboilerplate/pkg/log"
 	"git.backbone/corpix/goboilerplate/pkg/meta"
+	"git.backbone/corpix/goboilerplate/pkg/server"
 	"git.backbone/corpix/goboilerplate/pkg/telemetry"
 )
 
@@ -39,6 +41,43 @@ type Config struct {
 	Telemetry *telemetry.Config
 
 	ShutdownGraceTime time.Duration
+
+	// applied is a snapshot of configuration in use, updates are compared with it
+	// (updates share nested structs with current configuration, so it is serialized).
+	applied *snapshot
+}
+
+// snapshot is a serialized configuration,
+// telemetry TLS is kept apart because it could be reloaded without upgrade.
+type snapshot struct {
+	config []byte
+	tls    []byte
+}
+
+func newSnapshot(c *Config) (*snapshot, error) {
+	buf, err := Marshaler(c)
+	if err != nil {
+		return nil, err
+	}
+	cc := &Config{}
+	err = Unmarshaler(buf, cc)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &snapshot{}
+	if cc.Telemetry != nil && cc.Telemetry.HTTP != nil {
+		s.tls, err = Marshaler(cc.Telemetry.HTTP.TLS)
+		if err != nil {
+			return nil, err
+		}
+		cc.Telemetry.HTTP.TLS = nil
+	}
+	s.config, err = Marshaler(cc)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
 func (c *Config) Default() {
@@ -57,11 +96,41 @@ loop:
 	}
 }
 
+// Update sends configuration update to the bus,
+// when only telemetry TLS has changed TLSSubsystem update with new *server.TLSConfig is sent,
+// so certificates could be reloaded without upgrade.
 func (c *Config) Update(cc interface{}) error {
-	bus.Config <- bus.ConfigUpdate{
+	u := bus.ConfigUpdate{
 		Subsystem: Subsystem,
 		Config:    cc,
 	}
+
+	next, ok := cc.(*Config)
+	if ok {
+		s, err := newSnapshot(next)
+		if err != nil {
+			return err
+		}
+		if c.applied != nil && bytes.Equal(c.applied.config, s.config) && !bytes.Equal(c.applied.tls, s.tls) {
+			tls := &server.TLSConfig{}
+			err = Unmarshaler(s.tls, tls)
+			if err != nil {
+				return err
+			}
+			err = Postprocess(tls)
+			if err != nil {
+				return err
+			}
+			u = bus.ConfigUpdate{
+				Subsystem: server.TLSSubsystem,
+				Config:    tls,
+			}
+		}
+		// next is copied into current configuration after update
+		next.applied = s
+	}
+
+	bus.Config <- u
 	return nil
 }
 
@@ -168,6 +237,11 @@ func Load(paths []string, postprocessors ...revip.Option) (*Config, error) {
 		return nil, err
 	}
 
+	c.applied, err = newSnapshot(c)
+	if err != nil {
+		return nil, err
+	}
+
 	return c, nil
 }
 
@@ -14,6 +14,10 @@ var (
 	Wrapf   = errors.Wrapf
 	Cause   = errors.Cause
 	HasType = errors.HasType
+	As      = errors.As
+	Is      = errors.Is
+
+	CombineErrors = errors.CombineErrors
 )
 
 func Fatal(err error) {
@@ -42,8 +42,12 @@ const (
 )
 
 var (
-	TypeOf  = reflect.TypeOf
-	ValueOf = reflect.ValueOf
+	TypeOf    = reflect.TypeOf
+	ValueOf   = reflect.ValueOf
+	New       = reflect.New
+	MakeSlice = reflect.MakeSlice
+	MakeMap   = reflect.MakeMap
+	Append    = reflect.Append
 )
 
 func CheckValue(v interface{}) error {
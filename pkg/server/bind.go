@@ -0,0 +1,102 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	echo "github.com/labstack/echo/v4"
+	msgpack "github.com/vmihailenco/msgpack/v5"
+
+	"git.backbone/corpix/goboilerplate/pkg/reflect"
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+)
+
+const KindBind = "bind_failed"
+
+// Binder fills structs from request and validates them, it implements echo.Binder.
+// Binding is done in following order (each step could override previous):
+//  1. path params (`param` tag);
+//  2. query params (`query` tag), only for GET, HEAD and DELETE requests;
+//  3. headers (`header` tag, same grammar as EncodeHeaders);
+//  4. body: JSON, msgpack, XML or form (`form` tag).
+type Binder struct {
+	echo.DefaultBinder
+	validator echo.Validator
+}
+
+func (b *Binder) Bind(v interface{}, c Context) error {
+	err := b.bind(v, c)
+	if err != nil {
+		e := serverErrors.From(err)
+		if e.Code == http.StatusBadRequest && e.Kind == "" {
+			e.Kind = KindBind
+		}
+		return e
+	}
+
+	if b.validator != nil {
+		return b.validator.Validate(v)
+	}
+	return nil
+}
+
+func (b *Binder) bind(v interface{}, c Context) error {
+	err := b.BindPathParams(c, v)
+	if err != nil {
+		return err
+	}
+
+	switch c.Request().Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		err = b.BindQueryParams(c, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = b.BindHeaders(c, v)
+	if err != nil {
+		return err
+	}
+
+	return b.BindBody(c, v)
+}
+
+// BindHeaders binds headers using `header` tag, see DecodeHeaders.
+func (b *Binder) BindHeaders(c Context, v interface{}) error {
+	if reflect.IndirectValue(reflect.ValueOf(v)).Kind() != reflect.Struct {
+		return nil // headers could be bound only into structs
+	}
+	err := DecodeHeaders(c.Request().Header, v)
+	if err != nil {
+		return NewError(http.StatusBadRequest, "failed to bind headers", err, nil)
+	}
+	return nil
+}
+
+// BindBody extends echo.DefaultBinder with msgpack support,
+// msgpack keys are taken from `json` tags (like in responses).
+func (b *Binder) BindBody(c Context, v interface{}) error {
+	req := c.Request()
+	if req.ContentLength == 0 {
+		return nil
+	}
+
+	if strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationMsgpack) {
+		dec := msgpack.NewDecoder(req.Body)
+		// same keys as in responses encoded by response package
+		dec.SetCustomStructTag("json")
+
+		err := dec.Decode(v)
+		if err != nil {
+			return NewError(http.StatusBadRequest, "failed to decode msgpack body", err, nil)
+		}
+		return nil
+	}
+
+	return b.DefaultBinder.BindBody(c, v)
+}
+
+func NewBinder(validator echo.Validator) *Binder {
+	return &Binder{validator: validator}
+}
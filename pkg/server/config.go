@@ -1,12 +1,43 @@
 package server
 
 import (
+	"crypto/tls"
+	"net"
+	"sort"
 	"time"
+
+	"github.com/labstack/gommon/bytes"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+
+	"git.backbone/corpix/goboilerplate/pkg/server/csrf"
+	"git.backbone/corpix/goboilerplate/pkg/server/idempotency"
+	"git.backbone/corpix/goboilerplate/pkg/server/middleware"
+	"git.backbone/corpix/goboilerplate/pkg/server/proxyproto"
+	"git.backbone/corpix/goboilerplate/pkg/server/ratelimit"
+	"git.backbone/corpix/goboilerplate/pkg/server/secure"
+	"git.backbone/corpix/goboilerplate/pkg/server/static"
+	"git.backbone/corpix/goboilerplate/pkg/server/template"
+	"git.backbone/corpix/goboilerplate/pkg/server/websocket"
 )
 
 type Config struct {
-	Timeout     *TimeoutConfig     `yaml:"timeout"`
-	IPExtractor *IPExtractorConfig `yaml:"ip-extractor"`
+	Timeout     *TimeoutConfig              `yaml:"timeout"`
+	Limit       *LimitConfig                `yaml:"limit"`
+	Compress    *CompressConfig             `yaml:"compress"`
+	Telemetry   *middleware.TelemetryConfig `yaml:"telemetry"`
+	HTTP2       *HTTP2Config                `yaml:"http2"`
+	IPExtractor *IPExtractorConfig          `yaml:"ip-extractor"`
+	Template    *template.Config            `yaml:"template"`
+	Static      *static.Config              `yaml:"static"`
+	RateLimit   *ratelimit.Config           `yaml:"rate-limit"`
+	Secure      *secure.Config              `yaml:"secure"`
+	Idempotency *idempotency.Config         `yaml:"idempotency"`
+	CSRFOrigin  *csrf.OriginConfig          `yaml:"csrf-origin"`
+
+	ProxyProtocol *proxyproto.Config `yaml:"proxy-protocol"`
+	TLS           *TLSConfig         `yaml:"tls"`
+	WebSocket     *websocket.Config  `yaml:"websocket"`
 }
 
 func (c *Config) Default() {
@@ -15,37 +46,179 @@ loop:
 		switch {
 		case c.Timeout == nil:
 			c.Timeout = &TimeoutConfig{}
+		case c.Limit == nil:
+			c.Limit = &LimitConfig{}
+		case c.Compress == nil:
+			c.Compress = &CompressConfig{}
+		case c.Telemetry == nil:
+			c.Telemetry = &middleware.TelemetryConfig{}
+		case c.HTTP2 == nil:
+			c.HTTP2 = &HTTP2Config{}
 		case c.IPExtractor == nil:
 			c.IPExtractor = &IPExtractorConfig{}
+		case c.Template == nil:
+			c.Template = &template.Config{}
+		case c.Static == nil:
+			c.Static = &static.Config{}
+		case c.RateLimit == nil:
+			c.RateLimit = &ratelimit.Config{}
+		case c.Idempotency == nil:
+			c.Idempotency = &idempotency.Config{}
+		case c.Secure == nil:
+			c.Secure = &secure.Config{}
+		case c.CSRFOrigin == nil:
+			c.CSRFOrigin = &csrf.OriginConfig{}
+		case c.ProxyProtocol == nil:
+			c.ProxyProtocol = &proxyproto.Config{}
+		case c.TLS == nil:
+			c.TLS = &TLSConfig{}
+		case c.WebSocket == nil:
+			c.WebSocket = &websocket.Config{}
 		default:
 			break loop
 		}
 	}
 }
 
+func (c *Config) Validate() error {
+	if c.IPExtractor.Strategy == IPExtractorProxyProtocol && !c.ProxyProtocol.Enable {
+		return errors.Errorf(
+			"ip-extractor strategy %q requires proxy-protocol to be enabled",
+			c.IPExtractor.Strategy,
+		)
+	}
+	return nil
+}
+
+//
+
+type CompressConfig struct {
+	Enable bool `yaml:"enable"`
+	// Encodings are content codings in server preference order,
+	// one of: zstd, gzip (or encodings registered in middleware.CompressEncoders, like br).
+	Encodings []string `yaml:"encodings"`
+	// MinSize is a minimal response size to compress (like 1K), flushed responses are compressed regardless.
+	MinSize string `yaml:"min-size"`
+	// ContentTypes are media types to compress, entries ending with "/" match by prefix.
+	ContentTypes []string `yaml:"content-types"`
+}
+
+func (c *CompressConfig) Default() {
+loop:
+	for {
+		switch {
+		case c.Encodings == nil:
+			c.Encodings = []string{middleware.EncodingZstd, middleware.EncodingGzip}
+		case c.MinSize == "":
+			c.MinSize = "1K"
+		case c.ContentTypes == nil:
+			c.ContentTypes = []string{
+				"text/",
+				"application/json",
+				"application/problem+json",
+				"application/javascript",
+				"application/xml",
+				"application/yaml",
+				"application/x-ndjson",
+				"application/wasm",
+				"image/svg+xml",
+			}
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *CompressConfig) Validate() error {
+	for _, encoding := range c.Encodings {
+		if _, ok := middleware.CompressEncoders[encoding]; !ok {
+			available := make([]string, 0, len(middleware.CompressEncoders))
+			for k := range middleware.CompressEncoders {
+				available = append(available, k)
+			}
+			sort.Strings(available)
+
+			return errors.Errorf(
+				"unexpected encoding %q, expected one of: %q",
+				encoding, available,
+			)
+		}
+	}
+	_, err := bytes.Parse(c.MinSize)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse min size %q", c.MinSize)
+	}
+	return nil
+}
+
 //
 
 type IPExtractorConfig struct {
-	TrustCIDR []string `yaml:"trust-cidr"`
+	// Strategy defines where client IP address is taken from,
+	// one of: direct, x-real-ip, x-forwarded-for, forwarded, proxy-protocol.
+	Strategy string `yaml:"strategy"`
+	// TrustCIDR is a list of additional trusted proxy ranges,
+	// loopback, link-local and private ranges (both IPv4 and IPv6)
+	// are controlled by corresponding toggles.
+	TrustCIDR       []string `yaml:"trust-cidr"`
+	TrustLoopback   *bool    `yaml:"trust-loopback"`
+	TrustLinkLocal  *bool    `yaml:"trust-link-local"`
+	TrustPrivateNet *bool    `yaml:"trust-private-net"`
 }
 
 func (c *IPExtractorConfig) Default() {
 loop:
 	for {
 		switch {
-		case len(c.TrustCIDR) == 0:
-			c.TrustCIDR = []string{"0.0.0.0/0"}
+		case c.Strategy == "":
+			c.Strategy = IPExtractorRealIP
+		case c.TrustLoopback == nil:
+			v := true
+			c.TrustLoopback = &v
+		case c.TrustLinkLocal == nil:
+			v := true
+			c.TrustLinkLocal = &v
+		case c.TrustPrivateNet == nil:
+			v := true
+			c.TrustPrivateNet = &v
 		default:
 			break loop
 		}
 	}
 }
 
+func (c *IPExtractorConfig) Validate() error {
+	if _, ok := IPExtractors[c.Strategy]; !ok {
+		available := make([]string, 0, len(IPExtractors))
+		for k := range IPExtractors {
+			available = append(available, k)
+		}
+		sort.Strings(available)
+
+		return errors.Errorf(
+			"unexpected strategy %q, expected one of: %q",
+			c.Strategy, available,
+		)
+	}
+	for _, cidr := range c.TrustCIDR {
+		_, _, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse trusted cidr %q", cidr)
+		}
+	}
+	return nil
+}
+
 //
 
 type TimeoutConfig struct {
-	Read  time.Duration
-	Write time.Duration
+	Read       time.Duration `yaml:"read"`
+	ReadHeader time.Duration `yaml:"read-header"`
+	Write      time.Duration `yaml:"write"`
+	Idle       time.Duration `yaml:"idle"`
+	// Handler limits whole request handling time by cancelling request context,
+	// zero disables the limit (useful for streaming endpoints).
+	Handler time.Duration `yaml:"handler"`
 }
 
 func (c *TimeoutConfig) Default() {
@@ -54,10 +227,143 @@ loop:
 		switch {
 		case c.Read <= 0:
 			c.Read = 5 * time.Second
+		case c.ReadHeader <= 0:
+			c.ReadHeader = c.Read
 		case c.Write <= 0:
 			c.Write = 5 * time.Second
+		case c.Idle <= 0:
+			c.Idle = 120 * time.Second
 		default:
 			break loop
 		}
 	}
 }
+
+func (c *TimeoutConfig) Validate() error {
+	if c.Handler < 0 {
+		return errors.New("handler timeout should not be negative")
+	}
+	return nil
+}
+
+//
+
+type LimitConfig struct {
+	MaxHeaderBytes int `yaml:"max-header-bytes"`
+	// Body is a default request body size limit (like 4M, 512K, 0 to forbid bodies).
+	Body string `yaml:"body"`
+	// Routes overrides body size limit for route paths (as registered in router).
+	Routes map[string]string `yaml:"routes"`
+}
+
+func (c *LimitConfig) Default() {
+loop:
+	for {
+		switch {
+		case c.MaxHeaderBytes <= 0:
+			c.MaxHeaderBytes = 1 << 20
+		case c.Body == "":
+			c.Body = "4M"
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *LimitConfig) Validate() error {
+	_, err := bytes.Parse(c.Body)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse body limit %q", c.Body)
+	}
+	for path, limit := range c.Routes {
+		_, err = bytes.Parse(limit)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse body limit %q for route %q", limit, path)
+		}
+	}
+	return nil
+}
+
+//
+
+type HTTP2Config struct {
+	// H2C enables HTTP/2 over cleartext TCP (prior knowledge and upgrade),
+	// HTTP/2 over TLS is negotiated with ALPN regardless of this option.
+	H2C                  bool   `yaml:"h2c"`
+	MaxConcurrentStreams uint32 `yaml:"max-concurrent-streams"`
+	MaxReadFrameSize     uint32 `yaml:"max-read-frame-size"`
+}
+
+func (c *HTTP2Config) Default() {
+loop:
+	for {
+		switch {
+		case c.MaxConcurrentStreams == 0:
+			c.MaxConcurrentStreams = 250
+		default:
+			break loop
+		}
+	}
+}
+
+//
+
+type TLSConfig struct {
+	Enable bool   `yaml:"enable"`
+	Cert   string `yaml:"cert"`
+	Key    string `yaml:"key"`
+	// ClientCA is a PEM bundle used to verify client certificates (mTLS).
+	ClientCA string `yaml:"client-ca"`
+	// ClientAuth is one of: none, request, require, verify-if-given, require-and-verify.
+	// Defaults to require-and-verify when ClientCA is set.
+	ClientAuth   string   `yaml:"client-auth"`
+	MinVersion   string   `yaml:"min-version"`
+	CipherSuites []string `yaml:"cipher-suites"`
+	// ReloadInterval defines how often certificate files are checked for changes.
+	ReloadInterval time.Duration `yaml:"reload-interval"`
+}
+
+func (c *TLSConfig) Default() {
+loop:
+	for {
+		switch {
+		case c.ClientAuth == "" && c.ClientCA != "":
+			c.ClientAuth = TLSClientAuthRequireAndVerify
+		case c.ClientAuth == "":
+			c.ClientAuth = TLSClientAuthNone
+		case c.MinVersion == "":
+			c.MinVersion = TLSVersion12
+		case c.ReloadInterval <= 0:
+			c.ReloadInterval = 10 * time.Second
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *TLSConfig) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.Cert == "" {
+		return errors.New("cert should not be empty")
+	}
+	if c.Key == "" {
+		return errors.New("key should not be empty")
+	}
+	auth, ok := TLSClientAuth[c.ClientAuth]
+	if !ok {
+		return errors.Errorf("unexpected client-auth %q", c.ClientAuth)
+	}
+	if auth >= tls.VerifyClientCertIfGiven && c.ClientCA == "" {
+		return errors.Errorf("client-ca should not be empty for client-auth %q", c.ClientAuth)
+	}
+	if _, ok := TLSVersions[c.MinVersion]; !ok {
+		return errors.Errorf("unexpected min-version %q", c.MinVersion)
+	}
+	_, err := TLSCipherSuites(c.CipherSuites)
+	if err != nil {
+		return err
+	}
+	return nil
+}
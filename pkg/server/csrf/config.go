@@ -2,9 +2,11 @@ package csrf
 
 import (
 	"io/ioutil"
+	"sort"
 	"time"
 
 	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/session"
 )
 
 type Config struct {
@@ -12,8 +14,12 @@ type Config struct {
 	KeyFile string `yaml:"key-file"`
 	key     []byte
 
-	TTL           time.Duration `yaml:"ttl"`
-	ParameterName string        `yaml:"parameter-name"`
+	TTL           time.Duration         `yaml:"ttl"`
+	ParameterName string                `yaml:"parameter-name"`
+	HeaderName    string                `yaml:"header-name"`
+	Mode          string                `yaml:"mode"`
+	Cookie        *session.CookieConfig `yaml:"cookie"`
+	OneTime       *OneTimeConfig        `yaml:"one-time"`
 }
 
 func (c *Config) Default() {
@@ -24,6 +30,22 @@ loop:
 			c.TTL = 6 * time.Hour
 		case c.ParameterName == "":
 			c.ParameterName = ParameterName
+		case c.HeaderName == "":
+			c.HeaderName = HeaderName
+		case c.Mode == "":
+			c.Mode = ModeURL
+		case c.Cookie == nil:
+			c.Cookie = &session.CookieConfig{}
+		case c.Cookie.Name == "":
+			// set before cookie defaults, otherwise session cookie name is used
+			// and csrf cookie overwrites session cookie
+			c.Cookie.Name = CookieName
+		case c.Cookie.HTTPOnly == nil:
+			// double-submit token should be readable by scripts to be echoed in header
+			b := false
+			c.Cookie.HTTPOnly = &b
+		case c.OneTime == nil:
+			c.OneTime = &OneTimeConfig{}
 		default:
 			break loop
 		}
@@ -58,5 +80,42 @@ func (c *Config) Validate() error {
 	if len(c.key) == 0 {
 		return errors.New("key length should be greater than zero")
 	}
+	if _, ok := Modes[c.Mode]; !ok {
+		available := make([]string, len(Modes))
+		n := 0
+		for k := range Modes {
+			available[n] = k
+			n++
+		}
+		sort.Strings(available)
+
+		return errors.Errorf(
+			"unexpected mode %q, expected one of: %q",
+			c.Mode, available,
+		)
+	}
 	return nil
 }
+
+//
+
+type OneTimeConfig struct {
+	// Enable makes all tokens single use,
+	// otherwise it could be enabled per route with CSRF.OneTime().
+	Enable bool `yaml:"enable"`
+	// Limit is a maximum number of spent nonces to keep in memory,
+	// when it is reached one-time tokens are rejected until spent nonces expire.
+	Limit int `yaml:"limit"`
+}
+
+func (c *OneTimeConfig) Default() {
+loop:
+	for {
+		switch {
+		case c.Limit <= 0:
+			c.Limit = 100000
+		default:
+			break loop
+		}
+	}
+}
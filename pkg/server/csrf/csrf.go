@@ -2,9 +2,11 @@ package csrf
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/binary"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	echo "github.com/labstack/echo/v4"
@@ -14,6 +16,7 @@ import (
 	"git.backbone/corpix/goboilerplate/pkg/crypto/container"
 	"git.backbone/corpix/goboilerplate/pkg/errors"
 	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/session"
 )
 
 type (
@@ -25,11 +28,38 @@ type (
 )
 
 const (
-	ContextKey    = "csrf"
-	ParameterName = "csrf"
+	ContextKey       = "csrf"
+	CookieContextKey = "csrf-cookie"
+	ParameterName    = "csrf"
+	HeaderName       = echo.HeaderXCSRFToken
 
 	SourceKey  PayloadKey = 0x10
 	SubjectKey PayloadKey = 0x20
+
+	// ModeURL binds token to the client IP and signed URL,
+	// token is passed in the query parameter.
+	ModeURL = "url"
+	// ModeSession binds token to the session identifier,
+	// token is passed in the header or form parameter.
+	ModeSession = "session"
+	// ModeDoubleSubmit stores token in the cookie and expects
+	// the same token to be passed in the header or form parameter.
+	ModeDoubleSubmit = "double-submit"
+	// ModeHeader accepts tokens passed only in the header,
+	// this relies on the fact cross-origin requests could not set
+	// custom headers without CORS preflight (useful for AJAX).
+	// Token is bound to the session identifier when request has one.
+	ModeHeader = "header"
+)
+
+var (
+	CookieName = session.Name + "_csrf"
+	Modes      = map[string]struct{}{
+		ModeURL:          {},
+		ModeSession:      {},
+		ModeDoubleSubmit: {},
+		ModeHeader:       {},
+	}
 )
 
 //
@@ -38,6 +68,8 @@ type CSRF struct {
 	config  *Config
 	encoder container.Encoder
 	rand    crypto.Rand
+	nonces  NonceStore
+	oneTime bool
 }
 
 func (t *CSRF) Checksum(nonce []byte, subject string) []byte {
@@ -129,15 +161,45 @@ func (t *CSRF) MustSignURLString(source string, u string) string {
 	return uu
 }
 
+// Validate checks token is bound to the source and subject,
+// in one-time mode token nonce is spent and ErrReplay is returned on reuse.
 func (t *CSRF) Validate(source string, subject string, token Token) error {
-	box, err := t.Unpack(token)
+	box, err := t.validate(source, subject, token)
 	if err != nil {
 		return err
 	}
 
+	if t.oneTime {
+		h := box.Header()
+		ok, err := t.nonces.Spend(h.Nonce, h.ValidBefore)
+		if err != nil {
+			return errors.Wrap(err, "failed to spend token nonce")
+		}
+		if !ok {
+			return ErrReplay{Nonce: h.Nonce}
+		}
+	}
+
+	return nil
+}
+
+// OneTime returns CSRF instance which shares configuration and nonce store
+// but treats all tokens as single use (could be used for high-value routes).
+func (t *CSRF) OneTime() *CSRF {
+	tt := *t
+	tt.oneTime = true
+	return &tt
+}
+
+func (t *CSRF) validate(source string, subject string, token Token) (container.Container, error) {
+	box, err := t.Unpack(token)
+	if err != nil {
+		return nil, err
+	}
+
 	err = box.Validate()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	//
@@ -152,19 +214,19 @@ func (t *CSRF) Validate(source string, subject string, token Token) error {
 	subjectChecksum := t.Checksum(nonce[:], subject)
 
 	if !bytes.Equal(tokenSourceChecksum, sourceChecksum) {
-		return errors.Errorf(
+		return nil, errors.Errorf(
 			"token source checksum %x is not equals requested to the source checksum %x for input %q",
 			tokenSourceChecksum, sourceChecksum, source,
 		)
 	}
 	if !bytes.Equal(tokenSubjectChecksum, subjectChecksum) {
-		return errors.Errorf(
+		return nil, errors.Errorf(
 			"token subject checksum %x is not equals requested to the subject checksum %x for input %q",
 			tokenSubjectChecksum, subjectChecksum, subject,
 		)
 	}
 
-	return nil
+	return box, nil
 }
 
 func (t *CSRF) ValidateURL(source string, u *url.URL) error {
@@ -180,25 +242,210 @@ func (t *CSRF) ValidateURL(source string, u *url.URL) error {
 }
 
 func (t *CSRF) ValidateContext(c echo.Context) error {
-	// NOTE: leave only path part bercause we don't care about scheme://hostname
-	// if token is cryptographically secure (and this is convenient for signer to use in templates)
-	// probably some additional strategy could be implemented in the future
-
-	u := *c.Request().URL
-	u.Scheme = ""
-	u.Host = ""
+	var err error
 
-	err := t.ValidateURL(c.RealIP(), &u)
+	switch t.config.Mode {
+	case ModeURL:
+		// NOTE: leave only path part bercause we don't care about scheme://hostname
+		// if token is cryptographically secure (and this is convenient for signer to use in templates)
+		u := *c.Request().URL
+		u.Scheme = ""
+		u.Host = ""
+
+		err = t.ValidateURL(c.RealIP(), &u)
+	case ModeDoubleSubmit:
+		token := t.TokenContext(c)
+		cookie, _ := c.Cookie(t.config.Cookie.Name)
+		switch {
+		case cookie == nil || cookie.Value == "":
+			err = errors.Errorf("cookie %q is missing", t.config.Cookie.Name)
+		case subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) != 1:
+			err = errors.Errorf("token is not equal to the token in cookie %q", t.config.Cookie.Name)
+		default:
+			err = t.Validate("", "", token)
+			if err == nil && t.oneTime {
+				// cookie token is spent, issue a new one
+				_, err = t.issueCookie(c)
+			}
+		}
+	default:
+		var source string
+		source, err = t.contextSource(c)
+		if err != nil {
+			return err
+		}
+		err = t.Validate(source, "", t.TokenContext(c))
+	}
 	if err != nil {
+		if errors.HasType(err, ErrNonceStoreFull{}) {
+			return serverErrors.NewError(
+				http.StatusServiceUnavailable, "CSRF token could not be verified, try again later",
+				err, nil,
+			).WithKind("csrf_unavailable")
+		}
+		if errors.HasType(err, ErrReplay{}) {
+			return serverErrors.NewError(
+				http.StatusForbidden, "CSRF token has already been used",
+				err, nil,
+			).WithKind("csrf_replay")
+		}
 		return serverErrors.NewError(
 			http.StatusBadRequest, "CSRF token validation failed",
 			err, nil,
-		)
+		).WithKind("csrf_invalid")
 	}
 
 	return nil
 }
 
+// SignContext issues a token for the current request according to the configured mode.
+// In url mode token is bound to the request URL (without scheme and host),
+// in double-submit mode token from the cookie is returned (cookie is issued if missing).
+func (t *CSRF) SignContext(c echo.Context) (Token, error) {
+	switch t.config.Mode {
+	case ModeURL:
+		return t.Sign(c.RealIP(), t.contextURL(c).String())
+	case ModeDoubleSubmit:
+		return t.cookieToken(c)
+	default:
+		source, err := t.contextSource(c)
+		if err != nil {
+			return "", err
+		}
+		return t.Sign(source, "")
+	}
+}
+
+func (t *CSRF) MustSignContext(c echo.Context) Token {
+	token, err := t.SignContext(c)
+	if err != nil {
+		panic(err)
+	}
+
+	return token
+}
+
+// PrepareContext makes sure state required to sign tokens is available for the request.
+// It should be called before response is committed, otherwise session or cookie
+// modifications will be lost.
+func (t *CSRF) PrepareContext(c echo.Context) error {
+	switch t.config.Mode {
+	case ModeSession:
+		_, err := t.contextSource(c)
+		return err
+	case ModeDoubleSubmit:
+		_, err := t.cookieToken(c)
+		return err
+	default:
+		return nil
+	}
+}
+
+// TokenContext returns token passed with the request.
+func (t *CSRF) TokenContext(c echo.Context) Token {
+	switch t.config.Mode {
+	case ModeURL:
+		return c.QueryParam(t.config.ParameterName)
+	case ModeHeader:
+		return c.Request().Header.Get(t.config.HeaderName)
+	default:
+		token := c.Request().Header.Get(t.config.HeaderName)
+		if token == "" {
+			token = c.FormValue(t.config.ParameterName)
+		}
+		return token
+	}
+}
+
+func (t *CSRF) Mode() string          { return t.config.Mode }
+func (t *CSRF) ParameterName() string { return t.config.ParameterName }
+func (t *CSRF) HeaderName() string    { return t.config.HeaderName }
+
+func (t *CSRF) contextURL(c echo.Context) *url.URL {
+	u := *c.Request().URL
+	u.Scheme = ""
+	u.Host = ""
+
+	q := u.Query()
+	q.Del(t.config.ParameterName)
+	u.RawQuery = q.Encode()
+
+	return &u
+}
+
+func (t *CSRF) contextSource(c echo.Context) (string, error) {
+	switch t.config.Mode {
+	case ModeURL:
+		return c.RealIP(), nil
+	case ModeSession:
+		store, ok := session.GetStore(c)
+		if !ok {
+			return "", errors.Errorf(
+				"failed to load session store from context key %q, session middleware is required for %q mode",
+				session.StoreContextKey, ModeSession,
+			)
+		}
+		return store.Session().ID()
+	case ModeHeader:
+		// session is optional here, identifier is not created to avoid
+		// writing sessions for clients which never asked for one
+		store, ok := session.GetStore(c)
+		if !ok {
+			return "", nil
+		}
+		id, _ := store.Session().LookupID()
+		return id, nil
+	default:
+		return "", nil
+	}
+}
+
+func (t *CSRF) cookieToken(c echo.Context) (Token, error) {
+	if token, ok := c.Get(CookieContextKey).(Token); ok {
+		return token, nil
+	}
+
+	cookie, _ := c.Cookie(t.config.Cookie.Name)
+	if cookie != nil {
+		_, err := t.validate("", "", cookie.Value)
+		if err == nil {
+			c.Set(CookieContextKey, cookie.Value)
+			return cookie.Value, nil
+		}
+	}
+
+	return t.issueCookie(c)
+}
+
+func (t *CSRF) issueCookie(c echo.Context) (Token, error) {
+	token, err := t.Sign("", "")
+	if err != nil {
+		return "", err
+	}
+
+	domain := t.config.Cookie.Domain
+	if domain == "" {
+		domain = c.Request().Host
+	}
+	// net/http: invalid Cookie.Domain "xxx.localhost:4180"; dropping domain attribute
+	domain = strings.Split(domain, ":")[0]
+
+	c.SetCookie(&http.Cookie{
+		Name:     t.config.Cookie.Name,
+		Value:    token,
+		Path:     t.config.Cookie.Path,
+		Domain:   domain,
+		MaxAge:   int(t.config.TTL / time.Second),
+		Expires:  time.Now().Add(t.config.TTL),
+		Secure:   *t.config.Cookie.Secure,
+		HttpOnly: *t.config.Cookie.HTTPOnly,
+		SameSite: session.SameSite[strings.ToLower(t.config.Cookie.SameSite)],
+	})
+	c.Set(CookieContextKey, token)
+
+	return token, nil
+}
+
 func (t *CSRF) Unpack(token Token) (container.Container, error) {
 	box, err := t.newContainer()
 	if err != nil {
@@ -224,7 +471,15 @@ func (t *CSRF) newContainer() (container.Container, error) {
 
 //
 
-func New(c Config, rand crypto.Rand) (*CSRF, error) {
+type Option = func(*CSRF)
+
+func WithNonceStore(s NonceStore) Option {
+	return func(t *CSRF) {
+		t.nonces = s
+	}
+}
+
+func New(c Config, rand crypto.Rand, options ...Option) (*CSRF, error) {
 	var err error
 
 	enc, err := container.NewSecretBoxEncoder(rand, c.key)
@@ -232,9 +487,26 @@ func New(c Config, rand crypto.Rand) (*CSRF, error) {
 		return nil, err
 	}
 
-	return &CSRF{
+	t := &CSRF{
 		config:  &c,
 		encoder: enc,
 		rand:    rand,
-	}, nil
+	}
+	if c.OneTime != nil {
+		t.oneTime = c.OneTime.Enable
+	}
+
+	for _, option := range options {
+		option(t)
+	}
+
+	if t.nonces == nil {
+		limit := 0
+		if c.OneTime != nil {
+			limit = c.OneTime.Limit
+		}
+		t.nonces = NewMemoryNonceStore(limit)
+	}
+
+	return t, nil
 }
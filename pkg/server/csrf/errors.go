@@ -0,0 +1,37 @@
+package csrf
+
+import (
+	"fmt"
+)
+
+type ErrOrigin struct {
+	Reason string
+	Origin string
+}
+
+func (e ErrOrigin) Error() string {
+	return fmt.Sprintf(
+		"request origin %q is not allowed, reason: %s",
+		e.Origin, e.Reason,
+	)
+}
+
+//
+
+type ErrReplay struct {
+	Nonce uint64
+}
+
+func (e ErrReplay) Error() string {
+	return fmt.Sprintf("token with nonce %d has already been used", e.Nonce)
+}
+
+//
+
+type ErrNonceStoreFull struct {
+	Limit int
+}
+
+func (e ErrNonceStoreFull) Error() string {
+	return fmt.Sprintf("nonce store is full (limit %d), waiting for nonces to expire", e.Limit)
+}
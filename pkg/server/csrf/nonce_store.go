@@ -0,0 +1,89 @@
+package csrf
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// NonceStore records spent token nonces to prevent token replay.
+type NonceStore interface {
+	// Spend marks nonce as used until expiration time,
+	// returns false if nonce was already spent and ErrNonceStoreFull
+	// if nonce could not be recorded.
+	Spend(nonce uint64, expires time.Time) (bool, error)
+}
+
+//
+
+type nonceEntry struct {
+	nonce   uint64
+	expires time.Time
+}
+
+type nonceQueue []nonceEntry
+
+func (q nonceQueue) Len() int            { return len(q) }
+func (q nonceQueue) Less(i, j int) bool  { return q[i].expires.Before(q[j].expires) }
+func (q nonceQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nonceQueue) Push(x interface{}) { *q = append(*q, x.(nonceEntry)) }
+func (q *nonceQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[:n-1]
+	return x
+}
+
+//
+
+var _ NonceStore = new(MemoryNonceStore)
+
+// MemoryNonceStore keeps spent nonces in memory until they expire.
+// Store is bounded, when limit is reached and no nonce has expired yet
+// ErrNonceStoreFull is returned, valid nonces are never evicted because
+// evicted nonce could be replayed (limit less or equal to zero means store is unbounded).
+type MemoryNonceStore struct {
+	lock   *sync.Mutex
+	limit  int
+	nonces map[uint64]time.Time
+	queue  *nonceQueue
+}
+
+func (s *MemoryNonceStore) Spend(nonce uint64, expires time.Time) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	for s.queue.Len() > 0 {
+		e := (*s.queue)[0]
+		if e.expires.After(now) {
+			break
+		}
+		heap.Pop(s.queue)
+		if s.nonces[e.nonce] == e.expires {
+			delete(s.nonces, e.nonce)
+		}
+	}
+
+	if t, ok := s.nonces[nonce]; ok && t.After(now) {
+		return false, nil
+	}
+	if s.limit > 0 && s.queue.Len() >= s.limit {
+		return false, ErrNonceStoreFull{Limit: s.limit}
+	}
+
+	s.nonces[nonce] = expires
+	heap.Push(s.queue, nonceEntry{nonce: nonce, expires: expires})
+
+	return true, nil
+}
+
+func NewMemoryNonceStore(limit int) *MemoryNonceStore {
+	return &MemoryNonceStore{
+		lock:   &sync.Mutex{},
+		limit:  limit,
+		nonces: map[uint64]time.Time{},
+		queue:  &nonceQueue{},
+	}
+}
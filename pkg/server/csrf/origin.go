@@ -0,0 +1,262 @@
+package csrf
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+const (
+	HeaderSecFetchSite = "Sec-Fetch-Site"
+	HeaderSecFetchMode = "Sec-Fetch-Mode"
+
+	FetchSiteSameOrigin = "same-origin"
+	FetchSiteSameSite   = "same-site"
+	FetchSiteCrossSite  = "cross-site"
+	FetchSiteNone       = "none"
+
+	OriginNull = "null"
+
+	OriginReasonFetchSite  = "fetch-site"
+	OriginReasonFetchMode  = "fetch-mode"
+	OriginReasonOrigin     = "origin"
+	OriginReasonNullOrigin = "null-origin"
+	OriginReasonReferer    = "referer"
+	OriginReasonMissing    = "missing"
+)
+
+type OriginPolicy struct {
+	Skip       bool `yaml:"skip"`
+	ReportOnly bool `yaml:"report-only"`
+
+	Methods         []string `yaml:"methods"`
+	AllowOrigins    []string `yaml:"allow-origins"`
+	AllowSameSite   bool     `yaml:"allow-same-site"`
+	AllowFetchModes []string `yaml:"allow-fetch-modes"`
+	RequireOrigin   bool     `yaml:"require-origin"`
+}
+
+func (c *OriginPolicy) Default() {
+loop:
+	for {
+		switch {
+		case len(c.Methods) == 0:
+			c.Methods = []string{
+				http.MethodPost,
+				http.MethodPut,
+				http.MethodPatch,
+				http.MethodDelete,
+			}
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *OriginPolicy) Validate() error {
+	for _, origin := range c.AllowOrigins {
+		if origin == OriginNull {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse allowed origin %q", origin)
+		}
+		if u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return errors.Errorf(
+				"allowed origin %q should be in form scheme://host[:port]",
+				origin,
+			)
+		}
+	}
+	return nil
+}
+
+//
+
+type OriginConfig struct {
+	Enable bool          `yaml:"enable"`
+	Policy *OriginPolicy `yaml:"policy"`
+	// Routes maps route path (as it was registered in the router) to the policy.
+	Routes map[string]*OriginPolicy `yaml:"routes"`
+}
+
+func (c *OriginConfig) Default() {
+loop:
+	for {
+		switch {
+		case c.Policy == nil:
+			c.Policy = &OriginPolicy{}
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *OriginConfig) Validate() error {
+	for path, policy := range c.Routes {
+		if policy == nil {
+			return errors.Errorf("route %q policy should not be empty", path)
+		}
+		err := policy.Validate()
+		if err != nil {
+			return errors.Wrapf(err, "failed to validate route %q policy", path)
+		}
+	}
+	return nil
+}
+
+//
+
+type originPolicy struct {
+	*OriginPolicy
+	methods    map[string]struct{}
+	origins    map[string]struct{}
+	fetchModes map[string]struct{}
+}
+
+func newOriginPolicy(p *OriginPolicy) *originPolicy {
+	p.Default()
+
+	op := &originPolicy{
+		OriginPolicy: p,
+		methods:      make(map[string]struct{}, len(p.Methods)),
+		origins:      make(map[string]struct{}, len(p.AllowOrigins)),
+		fetchModes:   make(map[string]struct{}, len(p.AllowFetchModes)),
+	}
+	for _, method := range p.Methods {
+		op.methods[strings.ToUpper(method)] = struct{}{}
+	}
+	for _, origin := range p.AllowOrigins {
+		op.origins[normalizeOrigin(origin)] = struct{}{}
+	}
+	for _, mode := range p.AllowFetchModes {
+		op.fetchModes[strings.ToLower(mode)] = struct{}{}
+	}
+
+	return op
+}
+
+func (p *originPolicy) allowed(c echo.Context, origin string) bool {
+	origin = normalizeOrigin(origin)
+	if origin == normalizeOrigin(c.Scheme()+"://"+c.Request().Host) {
+		return true
+	}
+	_, ok := p.origins[origin]
+	return ok
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(origin), "/")
+}
+
+//
+
+// Origin verifies request origin using Sec-Fetch-* headers
+// with fallback to Origin & Referer headers.
+type Origin struct {
+	policy *originPolicy
+	routes map[string]*originPolicy
+}
+
+// Policy returns policy which should be applied to the request.
+func (o *Origin) Policy(c echo.Context) *OriginPolicy {
+	return o.policyFor(c).OriginPolicy
+}
+
+func (o *Origin) policyFor(c echo.Context) *originPolicy {
+	if p, ok := o.routes[c.Path()]; ok {
+		return p
+	}
+	return o.policy
+}
+
+// Verify checks request against the policy, returning ErrOrigin on violation.
+func (o *Origin) Verify(c echo.Context) error {
+	var (
+		p   = o.policyFor(c)
+		req = c.Request()
+	)
+
+	if p.Skip {
+		return nil
+	}
+	if _, ok := p.methods[req.Method]; !ok {
+		return nil
+	}
+
+	origin := req.Header.Get(echo.HeaderOrigin)
+
+	if len(p.fetchModes) > 0 {
+		mode := strings.ToLower(req.Header.Get(HeaderSecFetchMode))
+		if _, ok := p.fetchModes[mode]; mode != "" && !ok {
+			return ErrOrigin{Reason: OriginReasonFetchMode, Origin: origin}
+		}
+	}
+
+	switch strings.ToLower(req.Header.Get(HeaderSecFetchSite)) {
+	case FetchSiteSameOrigin, FetchSiteNone:
+		return nil
+	case FetchSiteSameSite:
+		if p.AllowSameSite || (origin != "" && p.allowed(c, origin)) {
+			return nil
+		}
+		return ErrOrigin{Reason: OriginReasonFetchSite, Origin: origin}
+	case FetchSiteCrossSite:
+		if origin != "" && p.allowed(c, origin) {
+			return nil
+		}
+		return ErrOrigin{Reason: OriginReasonFetchSite, Origin: origin}
+	}
+
+	// browser did not send fetch metadata, falling back to origin & referer
+
+	switch origin {
+	case "":
+	case OriginNull:
+		if _, ok := p.origins[OriginNull]; ok {
+			return nil
+		}
+		return ErrOrigin{Reason: OriginReasonNullOrigin, Origin: origin}
+	default:
+		if p.allowed(c, origin) {
+			return nil
+		}
+		return ErrOrigin{Reason: OriginReasonOrigin, Origin: origin}
+	}
+
+	referer := req.Referer()
+	if referer != "" {
+		u, err := url.Parse(referer)
+		if err == nil && u.Scheme != "" && u.Host != "" && p.allowed(c, u.Scheme+"://"+u.Host) {
+			return nil
+		}
+		return ErrOrigin{Reason: OriginReasonReferer, Origin: referer}
+	}
+
+	if p.RequireOrigin {
+		return ErrOrigin{Reason: OriginReasonMissing}
+	}
+
+	return nil
+}
+
+//
+
+func NewOrigin(c OriginConfig) *Origin {
+	c.Default()
+
+	o := &Origin{
+		policy: newOriginPolicy(c.Policy),
+		routes: make(map[string]*originPolicy, len(c.Routes)),
+	}
+	for path, policy := range c.Routes {
+		o.routes[path] = newOriginPolicy(policy)
+	}
+
+	return o
+}
@@ -1,33 +1,65 @@
 package server
 
 import (
+	"encoding/json"
+	"mime"
+	"strings"
+
 	echo "github.com/labstack/echo/v4"
 
 	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
 )
 
+const MIMEApplicationProblemJSON = "application/problem+json"
+
 type (
 	HTTPError = echo.HTTPError
 	Error     = serverErrors.Error
 )
 
+// acceptsProblem reports whether client explicitly accepts RFC 7807 problem details.
+func acceptsProblem(c Context) bool {
+	for _, accept := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == MIMEApplicationProblemJSON {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultHTTPErrorHandler writes errors using Result envelope
+// (or problem details if client accepts application/problem+json),
+// errors are resolved with serverErrors.From.
 func DefaultHTTPErrorHandler(err error, c Context) {
-	if _, ok := err.(*HTTPError); ok {
-		c.Echo().DefaultHTTPErrorHandler(err, c)
+	if c.Response().Committed {
 		return
 	}
 
-	//
+	var (
+		e         = serverErrors.From(err)
+		requestID = c.Response().Header().Get(echo.HeaderXRequestID)
+		r         = NewErrorResultFrom(e, requestID)
+	)
 
-	code := StatusInternalServerError
-	r := NewErrorResult(code, "")
-
-	if e, ok := err.(*Error); ok {
-		r.Error.Code = e.Code
-		r.Error.Message = e.Error()
+	if c.Request().Method == echo.HEAD {
+		err = c.NoContent(e.Code)
+	} else if acceptsProblem(c) {
+		var buf []byte
+		buf, err = json.Marshal(NewProblemResult(r, c.Request().URL.Path))
+		if err == nil {
+			err = c.Blob(e.Code, MIMEApplicationProblemJSON, buf)
+		}
+	} else {
+		err = c.JSON(e.Code, r)
+	}
+	if err != nil {
+		c.Logger().Error(err)
 	}
-
-	_ = c.JSON(r.Error.Code, r)
 }
 
-var NewError = serverErrors.NewError
+var (
+	NewError   = serverErrors.NewError
+	ErrorFrom  = serverErrors.From
+	StatusKind = serverErrors.StatusKind
+)
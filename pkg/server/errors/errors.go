@@ -1,16 +1,42 @@
 package errors
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+
+	echo "github.com/labstack/echo/v4"
 
 	"git.backbone/corpix/goboilerplate/pkg/errors"
 )
 
+const (
+	KindInternal   = "internal"
+	KindValidation = "validation_failed"
+	KindTimeout    = "timeout"
+)
+
 type Error struct {
+	// Code is a HTTP status code.
 	Code int
+	// Kind is a machine-readable error code (like "rate_limited"),
+	// defaults to snake-cased status text.
+	Kind string
 	Text string
 	Err  error
 	Meta interface{}
+	// Fields holds per-field errors (usually from validation).
+	Fields []FieldError
+}
+
+// FieldError describes a problem with a single input field.
+// Kind with Params are enough for clients to build localized messages,
+// Message is a default english text.
+type FieldError struct {
+	Field   string                 `json:"field"`
+	Kind    string                 `json:"kind"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
 }
 
 func (e *Error) Error() string {
@@ -21,6 +47,10 @@ func (e *Error) Error() string {
 	return e.Text
 }
 
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 func (e *Error) Chain() error {
 	if e.Err != nil {
 		return errors.Wrap(e.Err, e.Error())
@@ -29,6 +59,66 @@ func (e *Error) Chain() error {
 	return e
 }
 
+// ErrorKind returns Kind or kind derived from status code.
+func (e *Error) ErrorKind() string {
+	if e.Kind != "" {
+		return e.Kind
+	}
+	return StatusKind(e.Code)
+}
+
+func (e *Error) WithKind(kind string) *Error {
+	e.Kind = kind
+	return e
+}
+
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	e.Fields = append(e.Fields, fields...)
+	return e
+}
+
+// StatusKind converts status text into snake-cased kind,
+// "Too Many Requests" becomes "too_many_requests".
+// Internal server errors (and unknown codes) are KindInternal,
+// so they have the same kind as errors converted by From.
+func StatusKind(code int) string {
+	text := http.StatusText(code)
+	if text == "" || code == http.StatusInternalServerError {
+		return KindInternal
+	}
+
+	kind := make([]rune, 0, len(text))
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			kind = append(kind, r)
+		case r == ' ' || r == '-':
+			kind = append(kind, '_')
+		}
+	}
+	return string(kind)
+}
+
+// From finds *Error or *echo.HTTPError in err chain (using errors.As)
+// and converts it to *Error, other errors become internal server errors.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		var text string
+		if he.Message != nil {
+			text = fmt.Sprint(he.Message)
+		}
+		return NewError(he.Code, text, he.Internal, nil)
+	}
+
+	return NewError(http.StatusInternalServerError, "", err, nil).WithKind(KindInternal)
+}
+
 func NewError(code int, text string, err error, meta interface{}) *Error {
 	return &Error{
 		Code: code,
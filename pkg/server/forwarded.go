@@ -0,0 +1,200 @@
+package server
+
+import (
+	"net"
+	"strings"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+// ForwardedElement represents single proxy hop from Forwarded header (RFC 7239).
+type ForwardedElement struct {
+	For   string
+	By    string
+	Host  string
+	Proto string
+}
+
+// ForIP returns parsed IP address from "for" parameter,
+// nil is returned for obfuscated identifiers and "unknown".
+func (e ForwardedElement) ForIP() net.IP {
+	node := e.For
+	if strings.HasPrefix(node, "[") {
+		// [2001:db8:cafe::17]:4711
+		end := strings.Index(node, "]")
+		if end < 0 {
+			return nil
+		}
+		return net.ParseIP(node[1:end])
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+	return net.ParseIP(node)
+}
+
+// ParseForwarded parses Forwarded header values (RFC 7239) into elements,
+// first element is the furthest from the server.
+func ParseForwarded(values []string) ([]ForwardedElement, error) {
+	elements := []ForwardedElement{}
+
+	for _, value := range values {
+		var (
+			element ForwardedElement
+			pair    strings.Builder
+			pairs   []string
+			quoted  bool
+			escaped bool
+		)
+
+		flushPair := func() {
+			pairs = append(pairs, strings.TrimSpace(pair.String()))
+			pair.Reset()
+		}
+		flushElement := func() error {
+			flushPair()
+			element = ForwardedElement{}
+			for _, p := range pairs {
+				if p == "" {
+					continue
+				}
+				kv := strings.SplitN(p, "=", 2)
+				if len(kv) != 2 {
+					return errors.Errorf("malformed forwarded pair %q", p)
+				}
+				v := kv[1]
+				switch strings.ToLower(strings.TrimSpace(kv[0])) {
+				case "for":
+					element.For = v
+				case "by":
+					element.By = v
+				case "host":
+					element.Host = v
+				case "proto":
+					element.Proto = strings.ToLower(v)
+				}
+			}
+			pairs = pairs[:0]
+			elements = append(elements, element)
+			return nil
+		}
+
+		for _, r := range value {
+			switch {
+			case escaped:
+				pair.WriteRune(r)
+				escaped = false
+			case quoted && r == '\\':
+				escaped = true
+			case r == '"':
+				quoted = !quoted
+			case quoted:
+				pair.WriteRune(r)
+			case r == ';':
+				flushPair()
+			case r == ',':
+				err := flushElement()
+				if err != nil {
+					return nil, err
+				}
+			default:
+				pair.WriteRune(r)
+			}
+		}
+		if quoted {
+			return nil, errors.Errorf("unterminated quoted string in forwarded header %q", value)
+		}
+		err := flushElement()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return elements, nil
+}
+
+//
+
+// forwardingHeaders could be spoofed by clients, so they are dropped unless request came from trusted proxy.
+var forwardingHeaders = []string{
+	HeaderForwarded,
+	HeaderXForwardedHost,
+	HeaderXForwardedProto,
+	HeaderXForwardedProtocol,
+	HeaderXForwardedSsl,
+	HeaderXUrlScheme,
+}
+
+func lastHeaderValue(h Headers, name string) string {
+	values := h.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+	parts := strings.Split(values[len(values)-1], ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
+
+// RewriteForwarded reconstructs original scheme & host of the request
+// from forwarding headers set by trusted proxies (strategy defines which headers are used),
+// forwarding headers sent by untrusted peers are removed.
+func RewriteForwarded(strategy string, options ...TrustOption) MiddlewareFunc {
+	checker := newIPChecker(options)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c Context) error {
+			req := c.Request()
+
+			trusted := false
+			switch strategy {
+			case IPExtractorDirect, IPExtractorProxyProtocol:
+				// there is no http proxy in front of us
+			default:
+				ip := net.ParseIP(ExtractIPDirect()(req))
+				trusted = ip != nil && checker.trust(ip)
+			}
+
+			if !trusted {
+				for _, h := range forwardingHeaders {
+					req.Header.Del(h)
+				}
+				return next(c)
+			}
+
+			var host, proto string
+			switch strategy {
+			case IPExtractorForwarded:
+				elements, err := ParseForwarded(req.Header.Values(HeaderForwarded))
+				if err != nil {
+					return NewError(StatusBadRequest, "malformed forwarded header", err, nil)
+				}
+				// walk from the nearest proxy while hops are trusted
+				for i := len(elements) - 1; i >= 0; i-- {
+					if elements[i].Host != "" {
+						host = elements[i].Host
+					}
+					if elements[i].Proto != "" {
+						proto = elements[i].Proto
+					}
+					ip := elements[i].ForIP()
+					if ip == nil || !checker.trust(ip) {
+						break
+					}
+				}
+			default:
+				host = lastHeaderValue(req.Header, HeaderXForwardedHost)
+				proto = strings.ToLower(lastHeaderValue(req.Header, HeaderXForwardedProto))
+			}
+
+			if host != "" {
+				req.Host = host
+				req.URL.Host = host
+			}
+			if proto != "" {
+				req.URL.Scheme = proto
+				// echo.Context.Scheme() relies on this header
+				req.Header.Set(HeaderXForwardedProto, proto)
+			}
+
+			return next(c)
+		}
+	}
+}
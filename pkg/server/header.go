@@ -1,100 +1,428 @@
 package server
 
 import (
+	"encoding"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"git.backbone/corpix/goboilerplate/pkg/errors"
 	"git.backbone/corpix/goboilerplate/pkg/reflect"
 )
 
+// Headers are encoded from (and decoded into) struct fields with `header` tag:
+//
+//	Field T `header:"X-Name[,format]"`
+//
+// Default format converts scalars (strings, numbers, bools, durations, times, text marshalers)
+// and comma-joined slices of them, other formats are:
+//
+//	json   - value is a JSON document (nil values are skipped);
+//	base64 - value (or its default representation) is base64 encoded;
+//	list   - slice elements are joined with ", ", decoding splits all header lines by comma;
+//	sf     - RFC 8941 structured field (item, list or dictionary for maps).
+//
+// Struct fields without tag are walked recursively.
 const (
 	EncodeHeadersTag              = "header"
 	EncodeHeadersTagOptsDelimiter = ","
 
-	EncodeHeadersTagFormatJSON = "json"
+	EncodeHeadersTagFormatJSON       = "json"
+	EncodeHeadersTagFormatBase64     = "base64"
+	EncodeHeadersTagFormatList       = "list"
+	EncodeHeadersTagFormatStructured = "sf"
 )
 
-func EncodeHeaders(h Headers, v interface{}) error {
+var (
+	bytesType           = reflect.TypeOf([]byte(nil))
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func headerTag(ft reflect.StructField) (string, string) {
 	var (
-		rt = reflect.IndirectType(reflect.TypeOf(v))
-		rv = reflect.IndirectValue(reflect.ValueOf(v))
-		l  = rt.NumField()
-
-		ft        reflect.StructField
-		key       string
-		keyOpts   []string
-		format    string
-		formatErr error
-
-		vf    reflect.Value
-		fv    interface{}
-		value string
+		keyOpts = strings.Split(ft.Tag.Get(EncodeHeadersTag), EncodeHeadersTagOptsDelimiter)
+		key     = keyOpts[0]
+		format  string
 	)
+	if len(keyOpts) > 1 {
+		format = keyOpts[1]
+	}
+	return key, format
+}
 
-loop:
-	for n := 0; n < l; n++ {
-		ft = rt.Field(n)
-		key = ft.Tag.Get(EncodeHeadersTag)
-		keyOpts = strings.Split(key, EncodeHeadersTagOptsDelimiter)
-		format = ""
+// headerNested reports whether untagged field should be walked recursively.
+func headerNested(ft reflect.StructField) bool {
+	t := reflect.IndirectType(ft.Type)
+	return ft.Tag.Get(EncodeHeadersTag) == "" &&
+		ft.Type.Kind() != reflect.Slice &&
+		t.Kind() == reflect.Struct &&
+		t != timeType
+}
 
-		//
+// headerTypes caches whether struct type has header tagged fields (see headerTagged).
+var headerTypes sync.Map
 
-		if len(keyOpts) > 1 {
-			key = keyOpts[0]
-			format = keyOpts[1]
+// headerTagged reports whether struct type or any struct nested into it
+// (which is walked by headerNested) has header tagged fields.
+func headerTagged(t reflect.Type) bool {
+	if tagged, ok := headerTypes.Load(t); ok {
+		return tagged.(bool)
+	}
+	tagged := headerTaggedWalk(t, map[reflect.Type]bool{})
+	headerTypes.Store(t, tagged)
+	return tagged
+}
+
+// headerTaggedWalk skips types which are already visited,
+// so self-referential types are walked once.
+func headerTaggedWalk(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+
+	for n := 0; n < t.NumField(); n++ {
+		ft := t.Field(n)
+		if !reflect.StructFieldExported(ft) {
+			continue
 		}
-		if key == "" || key == "-" {
+		if headerNested(ft) {
+			if headerTaggedWalk(reflect.IndirectType(ft.Type), visited) {
+				return true
+			}
 			continue
 		}
+		key, _ := headerTag(ft)
+		if key != "" && key != "-" {
+			return true
+		}
+	}
+	return false
+}
 
-		//
+//
 
-		vf = rv.Field(n)
-		fv = vf.Interface()
+func EncodeHeaders(h Headers, v interface{}) error {
+	rv := reflect.IndirectValue(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return reflect.NewErrUnexpecterKind(rv.Kind(), reflect.Struct)
+	}
+	return encodeHeaders(h, rv)
+}
 
-		//
+func encodeHeaders(h Headers, rv reflect.Value) error {
+	rt := rv.Type()
 
-		switch format {
-		case EncodeHeadersTagFormatJSON:
+	for n := 0; n < rt.NumField(); n++ {
+		var (
+			ft          = rt.Field(n)
+			vf          = rv.Field(n)
+			key, format = headerTag(ft)
+		)
+		if !reflect.StructFieldExported(ft) {
+			continue
+		}
+		if headerNested(ft) {
 			if reflect.IsNil(vf) {
-				continue loop
+				continue
 			}
+			err := encodeHeaders(h, reflect.IndirectValue(vf))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if key == "" || key == "-" || reflect.IsNil(vf) {
+			continue
+		}
 
-			buf, err := json.Marshal(fv)
+		value, err := encodeHeader(vf, format)
+		if err != nil {
+			return errors.Wrapf(
+				err, "failed to marshal format %q field %q into header %q with value %#v",
+				format, ft.Name, key, vf.Interface(),
+			)
+		}
+		if value == "" {
+			continue
+		}
+		h.Add(key, value)
+	}
+
+	return nil
+}
+
+func encodeHeader(v reflect.Value, format string) (string, error) {
+	switch format {
+	case EncodeHeadersTagFormatJSON:
+		buf, err := json.Marshal(v.Interface())
+		if err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	case EncodeHeadersTagFormatBase64:
+		v = reflect.IndirectValue(v)
+		if v.Type() == bytesType {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+		s, err := formatValue(v)
+		if err != nil || s == "" {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString([]byte(s)), nil
+	case EncodeHeadersTagFormatList:
+		v = reflect.IndirectValue(v)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return "", reflect.NewErrUnexpecterKind(v.Kind(), reflect.Slice, reflect.Array)
+		}
+		items := make([]string, v.Len())
+		for n := range items {
+			s, err := formatValue(v.Index(n))
 			if err != nil {
-				formatErr = err
-				goto fail
+				return "", err
 			}
-			value = string(buf)
-		default:
-			switch v := fv.(type) {
-			case []string:
-				value = strings.Join(v, ",")
-			default:
-				value = fmt.Sprintf("%s", v)
+			items[n] = s
+		}
+		return strings.Join(items, ", "), nil
+	case EncodeHeadersTagFormatStructured:
+		return encodeStructured(v)
+	case "":
+		return formatValue(v)
+	default:
+		return "", errors.Errorf("unsupported header format %q", format)
+	}
+}
+
+// formatValue converts value into string,
+// slices are joined with comma, times are formatted as RFC 3339.
+func formatValue(v reflect.Value) (string, error) {
+	v = reflect.IndirectValue(v)
+	if !v.IsValid() {
+		return "", nil
+	}
+
+	switch v.Type() {
+	case durationType:
+		return time.Duration(v.Int()).String(), nil
+	case timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339), nil
+	case bytesType:
+		return string(v.Bytes()), nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		buf, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(buf), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]string, v.Len())
+		for n := range items {
+			s, err := formatValue(v.Index(n))
+			if err != nil {
+				return "", err
 			}
+			items[n] = s
 		}
+		return strings.Join(items, ","), nil
+	default:
+		return fmt.Sprintf("%s", v.Interface()), nil
+	}
+}
 
-		//
+//
 
-		if value == "" {
-			continue loop
+// DecodeHeaders fills struct fields from headers, it is an inverse of EncodeHeaders.
+func DecodeHeaders(h Headers, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return reflect.NewErrPtrRequired(v)
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return reflect.NewErrUnexpecterKind(rv.Kind(), reflect.Struct)
+	}
+	_, err := decodeHeaders(h, rv, map[reflect.Type]bool{})
+	return err
+}
+
+// decodeHeaders reports whether any field was decoded,
+// so nested pointers are allocated only when needed.
+// Nested structs without header tagged fields are skipped,
+// as well as types which are already being decoded (self-referential types).
+func decodeHeaders(h Headers, rv reflect.Value, decoding map[reflect.Type]bool) (bool, error) {
+	var (
+		rt      = rv.Type()
+		decoded bool
+	)
+	decoding[rt] = true
+	defer delete(decoding, rt)
+
+	for n := 0; n < rt.NumField(); n++ {
+		var (
+			ft          = rt.Field(n)
+			vf          = rv.Field(n)
+			key, format = headerTag(ft)
+		)
+		if !reflect.StructFieldExported(ft) {
+			continue
+		}
+		if headerNested(ft) {
+			nt := reflect.IndirectType(ft.Type)
+			if decoding[nt] || !headerTagged(nt) {
+				continue
+			}
+			if vf.Kind() == reflect.Ptr && vf.IsNil() {
+				nv := reflect.New(vf.Type().Elem())
+				ok, err := decodeHeaders(h, nv.Elem(), decoding)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					vf.Set(nv)
+					decoded = true
+				}
+				continue
+			}
+			ok, err := decodeHeaders(h, reflect.IndirectValue(vf), decoding)
+			if err != nil {
+				return false, err
+			}
+			decoded = decoded || ok
+			continue
+		}
+		if key == "" || key == "-" {
+			continue
 		}
-		h.Add(key, value)
 
-		//
+		values := h.Values(key)
+		if len(values) == 0 {
+			continue
+		}
 
-	fail:
-		if formatErr != nil {
-			return errors.Wrapf(
-				formatErr, "failed to marshal format %q field %q into header %q with value %#v",
-				format, ft.Name, key, fv,
+		err := decodeHeader(values, vf, format)
+		if err != nil {
+			return false, errors.Wrapf(
+				err, "failed to unmarshal format %q header %q into field %q",
+				format, key, ft.Name,
 			)
 		}
+		decoded = true
 	}
 
+	return decoded, nil
+}
+
+func decodeHeader(values []string, v reflect.Value, format string) error {
+	switch format {
+	case EncodeHeadersTagFormatJSON:
+		return json.Unmarshal([]byte(values[0]), v.Addr().Interface())
+	case EncodeHeadersTagFormatBase64:
+		buf, err := base64.StdEncoding.DecodeString(values[0])
+		if err != nil {
+			return err
+		}
+		if v.Type() == bytesType {
+			v.SetBytes(buf)
+			return nil
+		}
+		return setValue(v, string(buf))
+	case EncodeHeadersTagFormatList, "":
+		// both split by comma, list is explicit about it
+		return setValue(v, strings.Join(values, ","))
+	case EncodeHeadersTagFormatStructured:
+		// multiple header lines are combined as defined in RFC 8941 section 4.2
+		return decodeStructured(strings.Join(values, ", "), v)
+	default:
+		return errors.Errorf("unsupported header format %q", format)
+	}
+}
+
+// setValue converts string into value type,
+// slices are split by comma, times are parsed as RFC 3339.
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), s)
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Type() {
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case bytesType:
+		v.SetBytes([]byte(s))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := strings.Split(s, ",")
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for n, item := range items {
+			err := setValue(slice.Index(n), strings.TrimSpace(item))
+			if err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return reflect.NewErrUnexpecterKind(
+			v.Kind(),
+			reflect.String, reflect.Bool, reflect.Int, reflect.Uint, reflect.Float64, reflect.Slice,
+		)
+	}
 	return nil
 }
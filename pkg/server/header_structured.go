@@ -0,0 +1,506 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/reflect"
+)
+
+// Structured field values (RFC 8941) support,
+// lists, dictionaries and items (without inner lists) are handled,
+// parameters are accepted while parsing but ignored.
+// see: https://www.rfc-editor.org/rfc/rfc8941.html
+
+const (
+	sfIntegerMax = 999999999999999
+	sfDecimalMax = 999999999999.999
+)
+
+func encodeStructured(v reflect.Value) (string, error) {
+	v = reflect.IndirectValue(v)
+	switch {
+	case v.Kind() == reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return "", errors.Errorf("structured field dictionary key should be a string, got %s", v.Type().Key())
+		}
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+
+		members := make([]string, len(keys))
+		for n, key := range keys {
+			if !sfIsKey(key) {
+				return "", errors.Errorf("invalid structured field dictionary key %q", key)
+			}
+			mv := reflect.IndirectValue(v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())))
+			if mv.Kind() == reflect.Bool && mv.Bool() {
+				members[n] = key
+				continue
+			}
+			item, err := encodeStructuredItem(mv)
+			if err != nil {
+				return "", err
+			}
+			members[n] = key + "=" + item
+		}
+		return strings.Join(members, ", "), nil
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type() != bytesType:
+		members := make([]string, v.Len())
+		for n := 0; n < v.Len(); n++ {
+			item, err := encodeStructuredItem(reflect.IndirectValue(v.Index(n)))
+			if err != nil {
+				return "", err
+			}
+			members[n] = item
+		}
+		return strings.Join(members, ", "), nil
+	default:
+		return encodeStructuredItem(v)
+	}
+}
+
+func encodeStructuredItem(v reflect.Value) (string, error) {
+	if v.Type() == bytesType {
+		return ":" + base64.StdEncoding.EncodeToString(v.Bytes()) + ":", nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return "?1", nil
+		}
+		return "?0", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() != durationType {
+			i := v.Int()
+			if i > sfIntegerMax || i < -sfIntegerMax {
+				return "", errors.Errorf("integer %d is out of structured field range", i)
+			}
+			return strconv.FormatInt(i, 10), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := v.Uint()
+		if u > sfIntegerMax {
+			return "", errors.Errorf("integer %d is out of structured field range", u)
+		}
+		return strconv.FormatUint(u, 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := math.Round(v.Float()*1000) / 1000
+		if math.Abs(f) > sfDecimalMax || math.IsNaN(f) {
+			return "", errors.Errorf("decimal %f is out of structured field range", f)
+		}
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, nil
+	}
+
+	s, err := formatValue(v)
+	if err != nil {
+		return "", err
+	}
+	return sfQuote(s)
+}
+
+func sfQuote(s string) (string, error) {
+	var b strings.Builder
+	b.WriteByte('"')
+	for n := 0; n < len(s); n++ {
+		c := s[n]
+		if c < 0x20 || c > 0x7e {
+			return "", errors.Errorf("structured field string %q contains non-printable ascii character", s)
+		}
+		if c == '"' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('"')
+	return b.String(), nil
+}
+
+func sfIsKey(key string) bool {
+	if key == "" || !(key[0] == '*' || (key[0] >= 'a' && key[0] <= 'z')) {
+		return false
+	}
+	for n := 1; n < len(key); n++ {
+		c := key[n]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("_-.*", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+//
+
+func decodeStructured(s string, v reflect.Value) error {
+	p := &sfParser{s: s}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Kind() == reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		return p.parseDictionary(func(key string, item interface{}) error {
+			mv := reflect.New(v.Type().Elem()).Elem()
+			err := setStructured(mv, item)
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), mv)
+			return nil
+		})
+	case v.Kind() == reflect.Slice && v.Type() != bytesType:
+		slice := reflect.MakeSlice(v.Type(), 0, 0)
+		err := p.parseList(func(item interface{}) error {
+			ev := reflect.New(v.Type().Elem()).Elem()
+			err := setStructured(ev, item)
+			if err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, ev)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		v.Set(slice)
+		return nil
+	default:
+		p.skipSP()
+		item, err := p.parseItem()
+		if err != nil {
+			return err
+		}
+		p.skipSP()
+		if !p.eof() {
+			return p.errorf("unexpected trailing characters")
+		}
+		return setStructured(v, item)
+	}
+}
+
+func setStructured(v reflect.Value, item interface{}) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch it := item.(type) {
+	case bool:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(it)
+			return nil
+		}
+	case int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.OverflowInt(it) {
+				return errors.Errorf("integer %d overflows %s", it, v.Type())
+			}
+			v.SetInt(it)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if it < 0 || v.OverflowUint(uint64(it)) {
+				return errors.Errorf("integer %d overflows %s", it, v.Type())
+			}
+			v.SetUint(uint64(it))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(it))
+			return nil
+		}
+	case float64:
+		if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+			v.SetFloat(it)
+			return nil
+		}
+	case []byte:
+		if v.Type() == bytesType {
+			v.SetBytes(it)
+			return nil
+		}
+	case string:
+		return setValue(v, it)
+	}
+
+	if v.Kind() == reflect.String {
+		switch it := item.(type) {
+		case []byte:
+			v.SetString(string(it))
+		default:
+			v.SetString(fmt.Sprint(it))
+		}
+		return nil
+	}
+
+	return errors.Errorf("failed to assign structured field item %#v to %s", item, v.Type())
+}
+
+//
+
+type sfParser struct {
+	s   string
+	pos int
+}
+
+func (p *sfParser) eof() bool { return p.pos >= len(p.s) }
+func (p *sfParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *sfParser) skipSP() {
+	for !p.eof() && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *sfParser) skipOWS() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *sfParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf(
+		"failed to parse structured field %q at %d: %s",
+		p.s, p.pos, fmt.Sprintf(format, args...),
+	)
+}
+
+// members calls fn for each comma-separated member.
+func (p *sfParser) members(fn func() error) error {
+	p.skipSP()
+	for !p.eof() {
+		err := fn()
+		if err != nil {
+			return err
+		}
+		p.skipOWS()
+		if p.eof() {
+			return nil
+		}
+		if p.peek() != ',' {
+			return p.errorf("expected comma")
+		}
+		p.pos++
+		p.skipOWS()
+		if p.eof() {
+			return p.errorf("trailing comma")
+		}
+	}
+	return nil
+}
+
+func (p *sfParser) parseList(fn func(item interface{}) error) error {
+	return p.members(func() error {
+		if p.peek() == '(' {
+			return p.errorf("inner lists are not supported")
+		}
+		item, err := p.parseItem()
+		if err != nil {
+			return err
+		}
+		return fn(item)
+	})
+}
+
+func (p *sfParser) parseDictionary(fn func(key string, item interface{}) error) error {
+	return p.members(func() error {
+		key, err := p.parseKey()
+		if err != nil {
+			return err
+		}
+
+		var item interface{} = true
+		if p.peek() == '=' {
+			p.pos++
+			if p.peek() == '(' {
+				return p.errorf("inner lists are not supported")
+			}
+			item, err = p.parseItem()
+			if err != nil {
+				return err
+			}
+		} else {
+			err = p.parseParameters()
+			if err != nil {
+				return err
+			}
+		}
+		return fn(key, item)
+	})
+}
+
+func (p *sfParser) parseItem() (interface{}, error) {
+	item, err := p.parseBareItem()
+	if err != nil {
+		return nil, err
+	}
+	return item, p.parseParameters()
+}
+
+func (p *sfParser) parseParameters() error {
+	for p.peek() == ';' {
+		p.pos++
+		p.skipSP()
+		_, err := p.parseKey()
+		if err != nil {
+			return err
+		}
+		if p.peek() == '=' {
+			p.pos++
+			_, err = p.parseBareItem()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *sfParser) parseKey() (string, error) {
+	start := p.pos
+	for !p.eof() {
+		c := p.s[p.pos]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("_-.*", c) >= 0) {
+			break
+		}
+		p.pos++
+	}
+	key := p.s[start:p.pos]
+	if !sfIsKey(key) {
+		return "", p.errorf("invalid key %q", key)
+	}
+	return key, nil
+}
+
+func (p *sfParser) parseBareItem() (interface{}, error) {
+	c := p.peek()
+	switch {
+	case c == '-' || c >= '0' && c <= '9':
+		return p.parseNumber()
+	case c == '"':
+		return p.parseString()
+	case c == '*' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return p.parseToken(), nil
+	case c == ':':
+		return p.parseBytes()
+	case c == '?':
+		p.pos++
+		switch p.peek() {
+		case '1':
+			p.pos++
+			return true, nil
+		case '0':
+			p.pos++
+			return false, nil
+		}
+		return nil, p.errorf("invalid boolean")
+	default:
+		return nil, p.errorf("unexpected character %q", c)
+	}
+}
+
+func (p *sfParser) parseNumber() (interface{}, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	decimal := false
+	for !p.eof() {
+		c := p.s[p.pos]
+		if c == '.' && !decimal {
+			decimal = true
+		} else if c < '0' || c > '9' {
+			break
+		}
+		p.pos++
+	}
+	num := p.s[start:p.pos]
+
+	if decimal {
+		if strings.HasSuffix(num, ".") || len(num)-strings.IndexByte(num, '.')-1 > 3 {
+			return nil, p.errorf("invalid decimal %q", num)
+		}
+		return strconv.ParseFloat(num, 64)
+	}
+
+	i, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || i > sfIntegerMax || i < -sfIntegerMax {
+		return nil, p.errorf("invalid integer %q", num)
+	}
+	return i, nil
+}
+
+func (p *sfParser) parseString() (interface{}, error) {
+	p.pos++ // opening quote
+	var b strings.Builder
+	for !p.eof() {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '\\':
+			if p.eof() || (p.peek() != '"' && p.peek() != '\\') {
+				return nil, p.errorf("invalid escape")
+			}
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		case c == '"':
+			return b.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return nil, p.errorf("invalid string character %q", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+func (p *sfParser) parseToken() string {
+	start := p.pos
+	for !p.eof() {
+		c := p.s[p.pos]
+		if c <= 0x20 || c >= 0x7f || strings.IndexByte("\"(),;<=>?@[\\]{}", c) >= 0 {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *sfParser) parseBytes() (interface{}, error) {
+	p.pos++ // opening colon
+	end := strings.IndexByte(p.s[p.pos:], ':')
+	if end < 0 {
+		return nil, p.errorf("unterminated byte sequence")
+	}
+	buf, err := base64.StdEncoding.DecodeString(p.s[p.pos : p.pos+end])
+	if err != nil {
+		return nil, p.errorf("invalid byte sequence: %s", err)
+	}
+	p.pos += end + 1
+	return buf, nil
+}
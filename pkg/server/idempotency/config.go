@@ -0,0 +1,98 @@
+package idempotency
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/bytes"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/ratelimit"
+)
+
+type Config struct {
+	Enable bool `yaml:"enable"`
+	// Methods are request methods which honor Idempotency-Key header.
+	Methods []string `yaml:"methods"`
+	// Required rejects requests without Idempotency-Key header.
+	Required bool `yaml:"required"`
+	// TTL is how long recorded responses are replayed.
+	TTL time.Duration `yaml:"ttl"`
+	// LockTimeout is how long key stays locked by request in progress
+	// (it protects from keys locked forever if process crashes).
+	LockTimeout time.Duration `yaml:"lock-timeout"`
+	// Wait is how long concurrent duplicate waits for the first request to complete,
+	// zero responds with 409 Conflict immediately.
+	Wait time.Duration `yaml:"wait"`
+	// Scope is a name of the key extractor (see ratelimit.Keys) which isolates
+	// keys of different clients, keys are also scoped by method and path.
+	// Session scope requires session middleware to be registered before idempotency
+	// middleware (middleware registered by server.New runs before application middleware,
+	// so applications should construct it with middleware.NewIdempotency after session),
+	// requests without session identifier fall back to ip scope.
+	Scope        string `yaml:"scope"`
+	MaxKeyLength int    `yaml:"max-key-length"`
+	// MaxResponseSize is a size of the largest recorded response (like 1M),
+	// larger responses are not recorded.
+	MaxResponseSize string `yaml:"max-response-size"`
+	// MaxEntries is a maximum number of records kept by memory store,
+	// when it is reached requests with new keys are rejected until records expire.
+	MaxEntries int `yaml:"max-entries"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.Methods == nil:
+			c.Methods = []string{http.MethodPost, http.MethodPatch}
+		case c.TTL <= 0:
+			c.TTL = 24 * time.Hour
+		case c.LockTimeout <= 0:
+			c.LockTimeout = time.Minute
+		case c.Scope == "":
+			c.Scope = ratelimit.KeyNameIP
+		case c.MaxKeyLength <= 0:
+			c.MaxKeyLength = 255
+		case c.MaxResponseSize == "":
+			c.MaxResponseSize = "1M"
+		case c.MaxEntries <= 0:
+			c.MaxEntries = 1000
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.Wait < 0 {
+		return errors.New("wait should not be negative")
+	}
+	if c.Wait >= c.LockTimeout {
+		return errors.New("wait should be less than lock-timeout")
+	}
+	for _, method := range c.Methods {
+		if method != strings.ToUpper(method) {
+			return errors.Errorf("method %q should be upper case", method)
+		}
+	}
+	if _, ok := ratelimit.Keys[c.Scope]; !ok {
+		available := make([]string, 0, len(ratelimit.Keys))
+		for k := range ratelimit.Keys {
+			available = append(available, k)
+		}
+		sort.Strings(available)
+
+		return errors.Errorf(
+			"unexpected scope %q, expected one of: %q",
+			c.Scope, available,
+		)
+	}
+	_, err := bytes.Parse(c.MaxResponseSize)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse max response size %q", c.MaxResponseSize)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/bytes"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/ratelimit"
+)
+
+const (
+	HeaderIdempotencyKey      = "Idempotency-Key"
+	HeaderIdempotentReplayed  = "Idempotent-Replayed"
+	awaitPollInterval         = 50 * time.Millisecond
+	fingerprintFieldDelimiter = "\x00"
+)
+
+// ErrLocked is returned by Await when request with the same key is still in progress.
+var ErrLocked = errors.New("request with the same idempotency key is in progress")
+
+type Idempotency struct {
+	config          Config
+	store           Store
+	scope           ratelimit.KeyFunc
+	methods         map[string]struct{}
+	maxResponseSize int64
+}
+
+func (i *Idempotency) Config() Config { return i.config }
+func (i *Idempotency) Store() Store   { return i.store }
+
+// MaxResponseSize is a parsed Config.MaxResponseSize.
+func (i *Idempotency) MaxResponseSize() int64 { return i.maxResponseSize }
+
+// Applies reports whether requests with method honor Idempotency-Key.
+func (i *Idempotency) Applies(method string) bool {
+	_, ok := i.methods[method]
+	return ok
+}
+
+// ValidKey checks key is not empty, not too long and consists of printable ASCII characters.
+func (i *Idempotency) ValidKey(key string) bool {
+	if key == "" || len(key) > i.config.MaxKeyLength {
+		return false
+	}
+	for n := 0; n < len(key); n++ {
+		if key[n] < 0x20 || key[n] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// StoreKey scopes idempotency key by client, method and path.
+func (i *Idempotency) StoreKey(c echo.Context, key string) (string, error) {
+	scope, err := i.scope(c)
+	if err != nil {
+		return "", err
+	}
+	req := c.Request()
+	return scope + " " + req.Method + " " + req.URL.Path + " " + key, nil
+}
+
+// Fingerprint identifies request payload, so key reuse with different payload is detected.
+func (i *Idempotency) Fingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + fingerprintFieldDelimiter + req.URL.RequestURI() + fingerprintFieldDelimiter))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Recordable reports whether response could be replayed,
+// server errors are not recorded so request could be retried.
+func (i *Idempotency) Recordable(code int, size int) bool {
+	return code < http.StatusInternalServerError && int64(size) <= i.maxResponseSize
+}
+
+// Await waits up to Config.Wait for the record to be completed,
+// ErrLocked is returned if it is still in progress.
+func (i *Idempotency) Await(key string) (Record, bool, error) {
+	deadline := time.Now().Add(i.config.Wait)
+	for {
+		r, ok, err := i.store.Get(key)
+		if err != nil || !ok || r.Completed {
+			return r, ok, err
+		}
+		if !time.Now().Before(deadline) {
+			return r, ok, ErrLocked
+		}
+		time.Sleep(awaitPollInterval)
+	}
+}
+
+func New(c Config, s Store) (*Idempotency, error) {
+	maxResponseSize, err := bytes.Parse(c.MaxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	methods := make(map[string]struct{}, len(c.Methods))
+	for _, method := range c.Methods {
+		methods[method] = struct{}{}
+	}
+	return &Idempotency{
+		config:          c,
+		store:           s,
+		scope:           ratelimit.Keys[c.Scope],
+		methods:         methods,
+		maxResponseSize: maxResponseSize,
+	}, nil
+}
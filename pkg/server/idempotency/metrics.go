@@ -0,0 +1,30 @@
+package idempotency
+
+import (
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+)
+
+const (
+	ResultRecorded = "recorded"
+	ResultReplayed = "replayed"
+	ResultReleased = "released"
+	ResultConflict = "conflict"
+	ResultMismatch = "mismatch"
+	ResultFull     = "full"
+)
+
+type Metrics struct {
+	Requests *collector.CounterVec
+}
+
+func NewMetrics(r *registry.Registry, subsystem string) *Metrics {
+	f := registry.NewFactory(r, subsystem)
+	return &Metrics{
+		Requests: f.CounterVec(
+			"idempotency_requests_total",
+			"How many requests with idempotency key were handled, partitioned by result.",
+			"result",
+		),
+	}
+}
@@ -0,0 +1,130 @@
+package idempotency
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+// ErrStoreFull is returned by Lock when store could not keep more records.
+var ErrStoreFull = errors.New("idempotency store is full")
+
+// Record is a state of the idempotency key,
+// response fields are set when request is completed.
+type Record struct {
+	Fingerprint string
+	Completed   bool
+	Code        int
+	Header      http.Header
+	Body        []byte
+}
+
+// Store keeps idempotency records.
+type Store interface {
+	// Lock creates in-progress record for the key if it is missing (or expired),
+	// otherwise existing record is returned and false is reported.
+	// ErrStoreFull is returned if record could not be created.
+	Lock(key string, fingerprint string, ttl time.Duration) (Record, bool, error)
+	// Complete replaces in-progress record with completed one.
+	Complete(key string, r Record, ttl time.Duration) error
+	// Release removes in-progress record, so request could be retried.
+	Release(key string) error
+	Get(key string) (Record, bool, error)
+}
+
+//
+
+type memoryStoreEntry struct {
+	record  Record
+	expires time.Time
+}
+
+var _ Store = new(MemoryStore)
+
+// MemoryStore keeps records in memory, expired records are swept periodically.
+// Store is bounded, when limit is reached and no record has expired yet
+// ErrStoreFull is returned, so memory used is at most limit * max-response-size
+// (limit less or equal to zero means store is unbounded).
+type MemoryStore struct {
+	lock      *sync.Mutex
+	limit     int
+	entries   map[string]memoryStoreEntry
+	sweep     time.Duration
+	lastSweep time.Time
+}
+
+func (s *MemoryStore) Lock(key string, fingerprint string, ttl time.Duration) (Record, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.sweepExpired(now)
+
+	e, ok := s.entries[key]
+	if ok && e.expires.After(now) {
+		return e.record, false, nil
+	}
+	if !ok && s.limit > 0 && len(s.entries) >= s.limit {
+		s.lastSweep = time.Time{} // force sweep, periodic one could be too late
+		s.sweepExpired(now)
+		if len(s.entries) >= s.limit {
+			return Record{}, false, ErrStoreFull
+		}
+	}
+
+	r := Record{Fingerprint: fingerprint}
+	s.entries[key] = memoryStoreEntry{record: r, expires: now.Add(ttl)}
+	return r, true, nil
+}
+
+func (s *MemoryStore) Complete(key string, r Record, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r.Completed = true
+	s.entries[key] = memoryStoreEntry{record: r, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Release(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) Get(key string) (Record, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || !e.expires.After(time.Now()) {
+		return Record{}, false, nil
+	}
+	return e.record, true, nil
+}
+
+func (s *MemoryStore) sweepExpired(now time.Time) {
+	if now.Sub(s.lastSweep) < s.sweep {
+		return
+	}
+	for k, e := range s.entries {
+		if !e.expires.After(now) {
+			delete(s.entries, k)
+		}
+	}
+	s.lastSweep = now
+}
+
+func NewMemoryStore(limit int) *MemoryStore {
+	return &MemoryStore{
+		lock:      &sync.Mutex{},
+		limit:     limit,
+		entries:   map[string]memoryStoreEntry{},
+		sweep:     time.Minute,
+		lastSweep: time.Now(),
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"net"
 	"net/http"
 	"strings"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
 )
 
 type ipChecker struct {
@@ -95,14 +97,17 @@ func ExtractIPDirect() IPExtractor {
 
 // ExtractIPFromRealIPHeader extracts IP address using x-real-ip header.
 // Use this if you put proxy which uses this header.
+// Header is used only if request came from trusted proxy.
 func ExtractIPFromRealIPHeader(options ...TrustOption) IPExtractor {
 	checker := newIPChecker(options)
 	return func(req *http.Request) string {
 		directIP := ExtractIPDirect()(req)
 		realIP := req.Header.Get(HeaderXRealIP)
 		if realIP != "" {
-			if ip := net.ParseIP(realIP); ip != nil && checker.trust(ip) {
-				return realIP
+			if ip := net.ParseIP(directIP); ip != nil && checker.trust(ip) {
+				if ip := net.ParseIP(realIP); ip != nil {
+					return ip.String()
+				}
 			}
 		}
 		return directIP
@@ -135,3 +140,87 @@ func ExtractIPFromXFFHeader(options ...TrustOption) IPExtractor {
 		return strings.TrimSpace(ips[0])
 	}
 }
+
+// ExtractIPFromForwardedHeader extracts IP address using forwarded header (RFC 7239).
+// Use this if you put proxy which uses this header.
+// This returns nearest untrustable IP. If all IPs are trustable, returns furthest one.
+func ExtractIPFromForwardedHeader(options ...TrustOption) IPExtractor {
+	checker := newIPChecker(options)
+	return func(req *http.Request) string {
+		directIP := ExtractIPDirect()(req)
+		elements, err := ParseForwarded(req.Header[HeaderForwarded])
+		if err != nil || len(elements) == 0 {
+			return directIP
+		}
+
+		ip := net.ParseIP(directIP)
+		if ip == nil || !checker.trust(ip) {
+			return directIP
+		}
+		for i := len(elements) - 1; i >= 0; i-- {
+			ip = elements[i].ForIP()
+			if ip == nil {
+				// Unable to parse IP (obfuscated or unknown); cannot trust entire records
+				return directIP
+			}
+			if !checker.trust(ip) {
+				return ip.String()
+			}
+		}
+		// All of the IPs are trusted; return first element because it is furthest from server (best effort strategy).
+		return ip.String()
+	}
+}
+
+//
+
+const (
+	IPExtractorDirect        = "direct"
+	IPExtractorRealIP        = "x-real-ip"
+	IPExtractorXFF           = "x-forwarded-for"
+	IPExtractorForwarded     = "forwarded"
+	IPExtractorProxyProtocol = "proxy-protocol"
+)
+
+var IPExtractors = map[string]func(...TrustOption) IPExtractor{
+	IPExtractorDirect:    func(...TrustOption) IPExtractor { return ExtractIPDirect() },
+	IPExtractorRealIP:    ExtractIPFromRealIPHeader,
+	IPExtractorXFF:       ExtractIPFromXFFHeader,
+	IPExtractorForwarded: ExtractIPFromForwardedHeader,
+	// client address is recovered by the listener, so it is available as a remote address
+	IPExtractorProxyProtocol: func(...TrustOption) IPExtractor { return ExtractIPDirect() },
+}
+
+// NewTrustOptions creates trust options from configuration.
+func NewTrustOptions(c IPExtractorConfig) ([]TrustOption, error) {
+	options := []TrustOption{
+		TrustLoopback(*c.TrustLoopback),
+		TrustLinkLocal(*c.TrustLinkLocal),
+		TrustPrivateNet(*c.TrustPrivateNet),
+	}
+	for _, cidr := range c.TrustCIDR {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, TrustIPRange(ipnet))
+	}
+
+	return options, nil
+}
+
+// NewIPExtractor creates IP extractor using strategy from configuration.
+func NewIPExtractor(c IPExtractorConfig) (IPExtractor, error) {
+	options, err := NewTrustOptions(c)
+	if err != nil {
+		return nil, err
+	}
+
+	extractor, ok := IPExtractors[c.Strategy]
+	if !ok {
+		return nil, errors.Errorf("unsupported ip extractor strategy %q", c.Strategy)
+	}
+
+	return extractor(options...), nil
+}
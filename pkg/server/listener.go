@@ -0,0 +1,20 @@
+package server
+
+import (
+	"net"
+
+	"git.backbone/corpix/goboilerplate/pkg/server/proxyproto"
+)
+
+// NewListener wraps listener with server-level connection handling
+// like PROXY protocol header parsing.
+func NewListener(c Config, l net.Listener) (net.Listener, error) {
+	if c.ProxyProtocol.Enable {
+		pl, err := proxyproto.New(*c.ProxyProtocol, l)
+		if err != nil {
+			return nil, err
+		}
+		l = pl
+	}
+	return l, nil
+}
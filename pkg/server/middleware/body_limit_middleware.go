@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	echo "github.com/labstack/echo/v4"
+
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+)
+
+type bodyLimitReader struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (r *bodyLimitReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.read += int64(n)
+	if r.read > r.limit {
+		return n, newBodyLimitError(r.limit)
+	}
+	return n, err
+}
+
+func newBodyLimitError(limit int64) error {
+	return serverErrors.NewError(
+		http.StatusRequestEntityTooLarge, "",
+		nil, map[string]int64{"limit": limit},
+	).WithKind("body_too_large")
+}
+
+// NewBodyLimit limits request body size,
+// routes overrides limit for route paths (as registered in router).
+func NewBodyLimit(limit int64, routes map[string]int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			l := limit
+			if rl, ok := routes[c.Path()]; ok {
+				l = rl
+			}
+
+			req := c.Request()
+			if req.ContentLength > l {
+				return newBodyLimitError(l)
+			}
+			if req.Body != nil && req.Body != http.NoBody {
+				req.Body = &bodyLimitReader{ReadCloser: req.Body, limit: l}
+			}
+
+			return next(c)
+		}
+	}
+}
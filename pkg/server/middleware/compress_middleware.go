@@ -0,0 +1,311 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/response"
+)
+
+const (
+	EncodingGzip     = "gzip"
+	EncodingZstd     = "zstd"
+	EncodingBrotli   = "br"
+	EncodingIdentity = "identity"
+
+	mimeTextEventStream = "text/event-stream"
+)
+
+type (
+	// CompressWriter is a resettable streaming compressor,
+	// writers are pooled and reset for each response.
+	CompressWriter interface {
+		io.WriteCloser
+		Flush() error
+		Reset(w io.Writer)
+	}
+	// CompressEncoder produces content coding (RFC 7231 section 3.1.2.1),
+	// brotli is not bundled, it could be registered in CompressEncoders by application.
+	CompressEncoder struct {
+		Name      string
+		NewWriter func(w io.Writer) (CompressWriter, error)
+	}
+
+	compressPool struct {
+		encoder CompressEncoder
+		pool    sync.Pool
+	}
+)
+
+var CompressEncoders = map[string]CompressEncoder{
+	EncodingGzip: {
+		Name: EncodingGzip,
+		NewWriter: func(w io.Writer) (CompressWriter, error) {
+			return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+		},
+	},
+	EncodingZstd: {
+		Name: EncodingZstd,
+		NewWriter: func(w io.Writer) (CompressWriter, error) {
+			// responses are small and flushed often, concurrency does not help here
+			return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		},
+	},
+}
+
+func (p *compressPool) get(w io.Writer) (CompressWriter, error) {
+	if cw, ok := p.pool.Get().(CompressWriter); ok {
+		cw.Reset(w)
+		return cw, nil
+	}
+	return p.encoder.NewWriter(w)
+}
+
+func (p *compressPool) put(cw CompressWriter) {
+	cw.Reset(io.Discard)
+	p.pool.Put(cw)
+}
+
+//
+
+// compressResponseWriter buffers response until minSize bytes are written,
+// handler flushes or response ends, then decides whether to compress it.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	pool         *compressPool // nil when client does not accept any encoding
+	minSize      int
+	contentTypes []string
+
+	code    int
+	buf     []byte
+	decided bool
+	cw      CompressWriter
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.decided || w.code != 0 {
+		return
+	}
+	w.code = code
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) >= w.minSize {
+			err := w.decide(true)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush compresses streaming responses regardless of size (except event streams).
+func (w *compressResponseWriter) Flush() {
+	if !w.decided {
+		if w.code == 0 {
+			w.code = http.StatusOK
+		}
+		_ = w.decide(true)
+	}
+	if w.cw != nil {
+		_ = w.cw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+func (w *compressResponseWriter) close() error {
+	if !w.decided && w.code != 0 {
+		err := w.decide(len(w.buf) >= w.minSize)
+		if err != nil {
+			return err
+		}
+	}
+	if w.cw == nil {
+		return nil
+	}
+	err := w.cw.Close()
+	w.pool.put(w.cw)
+	w.cw = nil
+	return err
+}
+
+func (w *compressResponseWriter) decide(sizeOK bool) error {
+	w.decided = true
+
+	h := w.Header()
+	if w.compressible(h) {
+		response.AddVary(h, echo.HeaderAcceptEncoding)
+		if sizeOK && w.pool != nil {
+			cw, err := w.pool.get(w.ResponseWriter)
+			if err != nil {
+				return errors.Wrapf(err, "failed to create %q writer", w.pool.encoder.Name)
+			}
+			w.cw = cw
+
+			h.Del(echo.HeaderContentLength)
+			h.Set(echo.HeaderContentEncoding, w.pool.encoder.Name)
+			// representation changes, so validators should be weak
+			if etag := h.Get(response.HeaderETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set(response.HeaderETag, "W/"+etag)
+			}
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.code)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressResponseWriter) compressible(h http.Header) bool {
+	switch {
+	case w.code < http.StatusOK || w.code >= http.StatusMultipleChoices,
+		w.code == http.StatusNoContent,
+		w.code == http.StatusPartialContent:
+		return false
+	case h.Get(echo.HeaderContentEncoding) != "",
+		h.Get("Content-Range") != "":
+		return false
+	}
+
+	contentType := h.Get(echo.HeaderContentType)
+	if contentType == "" {
+		contentType = http.DetectContentType(w.buf)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == mimeTextEventStream {
+		return false
+	}
+	for _, t := range w.contentTypes {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+//
+
+// negotiateEncoding picks encoding with highest quality in Accept-Encoding,
+// on equal quality earlier (server preferred) encoding wins.
+func negotiateEncoding(accept string, pools []*compressPool) *compressPool {
+	qs := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "q") {
+				v, err := strconv.ParseFloat(kv[1], 64)
+				if err == nil {
+					q = v
+				}
+			}
+		}
+		qs[name] = q
+	}
+
+	var (
+		best  *compressPool
+		bestQ float64
+	)
+	for _, p := range pools {
+		q, ok := qs[p.encoder.Name]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = p, q
+		}
+	}
+	return best
+}
+
+//
+
+// NewCompress compresses responses with encoding negotiated by Accept-Encoding,
+// encoders are listed in server preference order.
+// Responses smaller than minSize (unless flushed) and content types
+// not matching contentTypes (exact media types or prefixes ending with "/") are sent as is,
+// event streams are never compressed because proxies could buffer them.
+func NewCompress(encoders []CompressEncoder, minSize int, contentTypes []string) echo.MiddlewareFunc {
+	pools := make([]*compressPool, len(encoders))
+	for n, encoder := range encoders {
+		pools[n] = &compressPool{encoder: encoder}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			req := c.Request()
+			if req.Method == http.MethodHead {
+				return next(c)
+			}
+
+			res := c.Response()
+			w := &compressResponseWriter{
+				ResponseWriter: res.Writer,
+				pool:           negotiateEncoding(req.Header.Get(echo.HeaderAcceptEncoding), pools),
+				minSize:        minSize,
+				contentTypes:   contentTypes,
+			}
+			res.Writer = w
+
+			// writer is restored on panic too, so recover middleware
+			// writes error response directly and encoder returns to the pool
+			defer func() {
+				res.Writer = w.ResponseWriter
+				cerr := w.close()
+				if err == nil {
+					err = cerr
+				}
+			}()
+
+			return next(c)
+		}
+	}
+}
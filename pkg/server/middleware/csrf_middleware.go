@@ -19,6 +19,8 @@ func NewCSRF(t *csrf.CSRF, methods []string) echo.MiddlewareFunc {
 		methods = []string{
 			http.MethodPost,
 			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
 		}
 	}
 	methodIndex := make(map[string]struct{}, len(methods))
@@ -37,6 +39,12 @@ func NewCSRF(t *csrf.CSRF, methods []string) echo.MiddlewareFunc {
 				}
 			}
 
+			// session or cookie state should be ready before response is committed
+			err := t.PrepareContext(c)
+			if err != nil {
+				return err
+			}
+
 			c.Set(CSRFContextKey, t)
 
 			return next(c)
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/server/csrf"
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+)
+
+// NewCSRFOrigin verifies Origin/Referer and Fetch Metadata headers of the request,
+// policy is chosen per route, violations are counted partitioned by reason.
+func NewCSRFOrigin(o *csrf.Origin, r *registry.Registry, subsystem string) echo.MiddlewareFunc {
+	violations := registry.NewFactory(r, subsystem).CounterVec(
+		"csrf_origin_violations_total",
+		"How many requests violated CSRF origin policy, partitioned by reason and report only mode.",
+		"reason", "report_only",
+	)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := o.Verify(c)
+			if err != nil {
+				var (
+					l      = c.Logger().(*Logger).Unwrap()
+					policy = o.Policy(c)
+					reason = csrf.OriginReasonOrigin
+				)
+
+				if e, ok := err.(csrf.ErrOrigin); ok {
+					reason = e.Reason
+				}
+				violations.WithLabelValues(reason, strconv.FormatBool(policy.ReportOnly)).Inc()
+
+				if policy.ReportOnly {
+					l.Warn().
+						Err(err).
+						Str("reason", reason).
+						Msg("csrf origin policy violation (report only)")
+					return next(c)
+				}
+
+				return serverErrors.NewError(
+					http.StatusForbidden, "CSRF origin verification failed",
+					err, map[string]string{"reason": reason},
+				).WithKind("csrf_origin")
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/idempotency"
+	"git.backbone/corpix/goboilerplate/pkg/server/response"
+)
+
+// idempotencyExcludeHeaders are specific to the original request (or added by outer middlewares)
+// and should not be replayed.
+var idempotencyExcludeHeaders = []string{
+	echo.HeaderSetCookie,
+	echo.HeaderXRequestID,
+	echo.HeaderContentEncoding,
+	echo.HeaderContentLength,
+	HeaderRateLimitLimit,
+	HeaderRateLimitRemaining,
+	HeaderRateLimitReset,
+}
+
+// idempotencyRecorder records response body up to maxSize,
+// larger and streamed responses are not recordable, so they are not buffered.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	code         int
+	body         bytes.Buffer
+	maxSize      int64
+	unrecordable bool
+}
+
+func (w *idempotencyRecorder) discard() {
+	w.unrecordable = true
+	w.body = bytes.Buffer{}
+}
+
+func (w *idempotencyRecorder) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *idempotencyRecorder) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if !w.unrecordable {
+		if int64(w.body.Len()+len(b)) > w.maxSize {
+			w.discard()
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush marks response as streamed, streams are not recorded.
+func (w *idempotencyRecorder) Flush() {
+	w.discard()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func replayIdempotent(c echo.Context, r idempotency.Record) error {
+	res := c.Response()
+	h := res.Header()
+	for k, v := range r.Header {
+		h[k] = append([]string(nil), v...)
+	}
+	h.Set(idempotency.HeaderIdempotentReplayed, "true")
+
+	res.WriteHeader(r.Code)
+	_, err := res.Write(r.Body)
+	return err
+}
+
+// NewIdempotency records responses of requests with Idempotency-Key header and replays them
+// for retries with the same key. Duplicates arriving while request is in progress wait
+// for it (see idempotency.Config.Wait) or get 409 Conflict, key reuse with different payload
+// gets 422, full store gets 503. Finalizer set by handler is dispatched here (with options), so its output is recorded.
+// Errors returned by handler are not recorded, key is released to allow retry.
+func NewIdempotency(i *idempotency.Idempotency, m *idempotency.Metrics, options ...response.FinalizerDispatchOption) echo.MiddlewareFunc {
+	var (
+		c     = i.Config()
+		store = i.Store()
+	)
+
+	count := func(result string) {
+		if m != nil {
+			m.Requests.WithLabelValues(result).Inc()
+		}
+	}
+	fail := func(code int, text string, kind string) error {
+		return serverErrors.NewError(code, text, nil, nil).WithKind(kind)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			if !i.Applies(req.Method) {
+				return next(ctx)
+			}
+
+			key := req.Header.Get(idempotency.HeaderIdempotencyKey)
+			switch {
+			case key == "" && c.Required:
+				return fail(http.StatusBadRequest, "idempotency key is required", "idempotency_key_required")
+			case key == "":
+				return next(ctx)
+			case !i.ValidKey(key):
+				return fail(http.StatusBadRequest, "idempotency key is invalid", "idempotency_key_invalid")
+			}
+
+			var body []byte
+			if req.Body != nil {
+				var err error
+				body, err = io.ReadAll(req.Body)
+				if err != nil {
+					return err
+				}
+				req.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			fingerprint := i.Fingerprint(req, body)
+			storeKey, err := i.StoreKey(ctx, key)
+			if err != nil {
+				return err
+			}
+
+			record, acquired, err := store.Lock(storeKey, fingerprint, c.LockTimeout)
+			if errors.Is(err, idempotency.ErrStoreFull) {
+				count(idempotency.ResultFull)
+				ctx.Response().Header().Set(HeaderRetryAfter, "1")
+				return serverErrors.NewError(
+					http.StatusServiceUnavailable, "idempotency key could not be recorded, try again later",
+					err, nil,
+				).WithKind("idempotency_unavailable")
+			}
+			if err != nil {
+				return errors.Wrap(err, "failed to lock idempotency key")
+			}
+			if !acquired {
+				if record.Fingerprint != fingerprint {
+					count(idempotency.ResultMismatch)
+					return fail(
+						http.StatusUnprocessableEntity,
+						"idempotency key was used with different request",
+						"idempotency_key_mismatch",
+					)
+				}
+				if !record.Completed {
+					var ok bool
+					record, ok, err = i.Await(storeKey)
+					if errors.Is(err, idempotency.ErrLocked) || (err == nil && !ok) {
+						count(idempotency.ResultConflict)
+						ctx.Response().Header().Set(HeaderRetryAfter, "1")
+						return fail(
+							http.StatusConflict,
+							"request with the same idempotency key is in progress",
+							"idempotency_conflict",
+						)
+					}
+					if err != nil {
+						return errors.Wrap(err, "failed to get idempotency record")
+					}
+				}
+				count(idempotency.ResultReplayed)
+				return replayIdempotent(ctx, record)
+			}
+
+			//
+
+			res := ctx.Response()
+			w := &idempotencyRecorder{ResponseWriter: res.Writer, maxSize: i.MaxResponseSize()}
+			res.Writer = w
+
+			err = next(ctx)
+			if err == nil && !res.Committed {
+				if f := response.GetFinalizer(ctx); f != nil {
+					// outer finalizer middleware should not dispatch it again
+					response.SetFinalizer(ctx, nil)
+					err = response.DispatchFinalizer(ctx, f, options...)
+				}
+			}
+			res.Writer = w.ResponseWriter
+
+			if err != nil || w.code == 0 || w.unrecordable || !i.Recordable(w.code, w.body.Len()) {
+				count(idempotency.ResultReleased)
+				rerr := store.Release(storeKey)
+				if err != nil {
+					return err
+				}
+				return errors.Wrap(rerr, "failed to release idempotency key")
+			}
+
+			header := res.Header().Clone()
+			for _, name := range idempotencyExcludeHeaders {
+				header.Del(name)
+			}
+			err = store.Complete(storeKey, idempotency.Record{
+				Fingerprint: fingerprint,
+				Code:        w.code,
+				Header:      header,
+				Body:        w.body.Bytes(),
+			}, c.TTL)
+			if err != nil {
+				return errors.Wrap(err, "failed to record idempotent response")
+			}
+			count(idempotency.ResultRecorded)
+
+			return nil
+		}
+	}
+}
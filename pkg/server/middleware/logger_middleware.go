@@ -6,6 +6,7 @@ import (
 
 	"git.backbone/corpix/goboilerplate/pkg/log"
 	"git.backbone/corpix/goboilerplate/pkg/server/errors"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/trace"
 
 	echo "github.com/labstack/echo/v4"
 )
@@ -34,15 +35,29 @@ func NewLogger(l log.Logger, msg string) echo.MiddlewareFunc {
 				start = time.Now()
 			)
 
-			ll := l.With().
+			llc := l.With().
 				Str("request_id", res.Header().Get(echo.HeaderXRequestID)).
 				Str("remote_ip", c.RealIP()).
 				Str("host", req.Host).
 				Str("method", req.Method).
 				Str("uri", req.RequestURI).
 				Str("user_agent", req.UserAgent()).
-				Str("referer", req.Referer()).
-				Logger()
+				Str("referer", req.Referer())
+			if sc := trace.SpanContextFromContext(req.Context()); sc.IsValid() {
+				llc = llc.
+					Str("trace_id", sc.TraceID.String()).
+					Str("span_id", sc.SpanID.String())
+			}
+			// NOTE: with request and require client-auth peer certificates are not verified,
+			// so their subject is logged separately and should not be trusted
+			switch {
+			case req.TLS == nil:
+			case len(req.TLS.VerifiedChains) > 0:
+				llc = llc.Str("client_subject", req.TLS.VerifiedChains[0][0].Subject.String())
+			case len(req.TLS.PeerCertificates) > 0:
+				llc = llc.Str("client_subject_unverified", req.TLS.PeerCertificates[0].Subject.String())
+			}
+			ll := llc.Logger()
 
 			ll.Trace().
 				Interface("headers", c.Request().Header).
@@ -73,18 +88,12 @@ func NewLogger(l log.Logger, msg string) echo.MiddlewareFunc {
 
 			// XXX: status is so clumzy because echo design is fucked
 			if err != nil {
-				if e, ok := err.(*errors.Error); ok {
-					evt.
-						Int("status", e.Code).
-						Interface("meta", e.Meta).
-						Err(e.Chain())
-				} else {
-
-					if e, ok := err.(*echo.HTTPError); ok {
-						evt.Int("status", e.Code)
-					}
-					evt.Err(err)
-				}
+				e := errors.From(err)
+				evt.
+					Int("status", e.Code).
+					Str("kind", e.ErrorKind()).
+					Interface("meta", e.Meta).
+					Err(e.Chain())
 			} else {
 				evt.Int("status", res.Status)
 			}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/ratelimit"
+)
+
+const (
+	HeaderRetryAfter         = "Retry-After"
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+)
+
+func rateLimitSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// NewRateLimit limits requests using limiter, metrics are optional.
+func NewRateLimit(l *ratelimit.Limiter, m *ratelimit.Metrics) echo.MiddlewareFunc {
+	key := l.KeyFunc()
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			k, err := key(c)
+			if err != nil {
+				return err
+			}
+
+			res, err := l.Take(k)
+			if err != nil {
+				return err
+			}
+
+			h := c.Response().Header()
+			h.Set(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+			h.Set(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+			h.Set(HeaderRateLimitReset, rateLimitSeconds(res.ResetAfter))
+
+			if !res.Allowed {
+				if m != nil {
+					m.Requests.WithLabelValues(l.Name(), "limited").Inc()
+				}
+
+				retryAfter := rateLimitSeconds(res.RetryAfter)
+				h.Set(HeaderRetryAfter, retryAfter)
+
+				return serverErrors.NewError(
+					http.StatusTooManyRequests, "",
+					nil, map[string]string{"policy": l.Name(), "retry_after": retryAfter},
+				).WithKind("rate_limited")
+			}
+
+			if m != nil {
+				m.Requests.WithLabelValues(l.Name(), "allowed").Inc()
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -8,6 +8,9 @@ import (
 
 const ResponseFinalizerContextKey = response.FinalizerContextKey
 
+// NewResponseFinalizer dispatches finalizer set by handler,
+// options could override defaults, like response.WithCodecs for response.Data.
+// GET and HEAD responses get ETag and 304 Not Modified handling (see response.DispatchConditional).
 func NewResponseFinalizer(options ...response.FinalizerDispatchOption) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -21,7 +24,7 @@ func NewResponseFinalizer(options ...response.FinalizerDispatchOption) echo.Midd
 				return nil
 			}
 
-			return response.DispatchFinalizer(c, finalizer, options...)
+			return response.DispatchConditional(c, finalizer, options...)
 		}
 	}
 }
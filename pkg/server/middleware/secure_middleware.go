@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	echo "github.com/labstack/echo/v4"
+
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/secure"
+)
+
+const (
+	CSPNonceContextKey = secure.NonceContextKey
+
+	cspReportMaxSize = 64 << 10
+)
+
+// NewSecure sets security headers (HSTS, CSP, frame, referrer and content type policies),
+// use secure.Nonce to get CSP nonce in handlers (templates have cspNonce function).
+func NewSecure(s *secure.Secure) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := s.Apply(c)
+			if err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// NewCSPReport collects CSP violation reports sent by browsers,
+// violations are logged and counted in metrics.
+func NewCSPReport(m *secure.Metrics) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		body, err := io.ReadAll(io.LimitReader(c.Request().Body, cspReportMaxSize))
+		if err != nil {
+			return err
+		}
+		reports, err := secure.ParseReports(c.Request().Header.Get(echo.HeaderContentType), body)
+		if err != nil {
+			return serverErrors.NewError(
+				http.StatusBadRequest, "failed to parse csp report",
+				err, nil,
+			).WithKind("csp_report_invalid")
+		}
+
+		l := c.Logger().(*Logger).Unwrap()
+		for _, r := range reports {
+			disposition := r.DispositionName()
+			if m != nil {
+				m.Violations.WithLabelValues(r.Directive(), disposition).Inc()
+			}
+
+			l.Warn().
+				Str("document_uri", r.DocumentURI).
+				Str("directive", r.Directive()).
+				Str("blocked_uri", r.BlockedURI).
+				Str("disposition", disposition).
+				Str("source_file", r.SourceFile).
+				Int("line_number", r.LineNumber).
+				Msg("csp violation")
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
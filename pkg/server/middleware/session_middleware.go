@@ -88,21 +88,45 @@ func NewSession(sc session.Config, rand crypto.Rand, options ...session.Option)
 			//
 
 			previousNonce := userSession.Header().Nonce
+			save := func() error {
+				currentNonce := userSession.Header().Nonce
+				if currentNonce <= previousNonce {
+					return nil
+				}
+
+				l.Debug().
+					Uint64("current-nonce", currentNonce).
+					Uint64("previous-nonce", previousNonce).
+					Msg("session nonce update")
+
+				previousNonce = currentNonce
+				return store.Save()
+			}
+
+			// session could be modified while response is being written
+			// (for example by template functions), so we save it right before
+			// headers are sent unless handler failed
+			failed := false
+			c.Response().Before(func() {
+				if failed {
+					return
+				}
+				err := save()
+				if err != nil {
+					l.Error().Err(err).Msg("failed to save session")
+				}
+			})
+
 			err = next(c)
 			if err != nil {
+				failed = true
 				return err
 			}
-			currentNonce := userSession.Header().Nonce
 
 			//
 
-			if currentNonce > previousNonce && !c.Response().Committed {
-				l.Debug().
-					Uint64("current-nonce", currentNonce).
-					Uint64("previous-nonce", previousNonce).
-					Msg("session nonce update")
-
-				err = store.Save()
+			if !c.Response().Committed {
+				err = save()
 				if err != nil {
 					return err
 				}
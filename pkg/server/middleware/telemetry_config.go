@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"sort"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
+)
+
+const (
+	TelemetryLabelCode        = "code"
+	TelemetryLabelStatusClass = "status_class"
+	TelemetryLabelMethod      = "method"
+	TelemetryLabelPath        = "path"
+
+	// TelemetryUnmatchedPath is a path label of requests which matched no route,
+	// so paths requested by scanners do not create new series.
+	TelemetryUnmatchedPath = "unmatched"
+)
+
+// TelemetryLabels are labels available to TelemetryConfig.Labels.
+var TelemetryLabels = map[string]struct{}{
+	TelemetryLabelCode:        {},
+	TelemetryLabelStatusClass: {},
+	TelemetryLabelMethod:      {},
+	TelemetryLabelPath:        {},
+}
+
+type TelemetryConfig struct {
+	// Labels is an allow-list of request metric labels,
+	// path label is a route template (like /users/:id), not a requested path.
+	Labels []string `yaml:"labels"`
+	// DurationBuckets are request duration histogram buckets in seconds.
+	DurationBuckets []float64 `yaml:"duration-buckets"`
+	// SizeBuckets are request and response size histogram buckets in bytes.
+	SizeBuckets []float64 `yaml:"size-buckets"`
+}
+
+func (c *TelemetryConfig) Default() {
+loop:
+	for {
+		switch {
+		case c.Labels == nil:
+			c.Labels = []string{TelemetryLabelCode, TelemetryLabelMethod, TelemetryLabelPath}
+		case c.DurationBuckets == nil:
+			c.DurationBuckets = collector.DefBuckets
+		case c.SizeBuckets == nil:
+			c.SizeBuckets = collector.ExponentialBuckets(128, 4, 10) // 128B .. 32M
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *TelemetryConfig) Validate() error {
+	seen := make(map[string]struct{}, len(c.Labels))
+	for _, label := range c.Labels {
+		if _, ok := TelemetryLabels[label]; !ok {
+			available := make([]string, 0, len(TelemetryLabels))
+			for k := range TelemetryLabels {
+				available = append(available, k)
+			}
+			sort.Strings(available)
+
+			return errors.Errorf(
+				"unexpected label %q, expected one of: %q",
+				label, available,
+			)
+		}
+		if _, ok := seen[label]; ok {
+			return errors.Errorf("label %q is duplicated", label)
+		}
+		seen[label] = struct{}{}
+	}
+	for name, buckets := range map[string][]float64{
+		"duration-buckets": c.DurationBuckets,
+		"size-buckets":     c.SizeBuckets,
+	} {
+		if !sort.Float64sAreSorted(buckets) {
+			return errors.Errorf("%s should be sorted in increasing order", name)
+		}
+	}
+	return nil
+}
@@ -2,15 +2,34 @@ package middleware
 
 import (
 	"net/http"
+	"reflect"
 	"strconv"
 	"time"
 
 	echo "github.com/labstack/echo/v4"
 
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
 	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
 	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/trace"
 )
 
+// telemetryMethods are methods used as label values as is,
+// other methods are reported as OTHER.
+var telemetryMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodPost:    {},
+	http.MethodPut:     {},
+	http.MethodPatch:   {},
+	http.MethodDelete:  {},
+	http.MethodConnect: {},
+	http.MethodOptions: {},
+	http.MethodTrace:   {},
+}
+
+var notFoundHandler = reflect.ValueOf(echo.NotFoundHandler).Pointer()
+
 func telemetryApproxRequestSize(r *http.Request) int {
 	s := 0
 	if r.URL != nil {
@@ -33,70 +52,112 @@ func telemetryApproxRequestSize(r *http.Request) int {
 	return s
 }
 
-func NewTelemetry(r *registry.Registry, subsystem string) echo.MiddlewareFunc {
-	reqTot := collector.NewCounterVec(
-		collector.CounterOpts{
-			Name: collector.Name(subsystem, "requests", "total"),
-			Help: "How many HTTP requests processed, partitioned by status code and HTTP method.",
-		},
-		[]string{"code", "method", "path"},
-	)
+// telemetryPath returns matched route template,
+// echo sets path to requested path when no route matched, so handler is checked too.
+func telemetryPath(c echo.Context) string {
+	path := c.Path()
+	if path == "" || c.Handler() == nil || reflect.ValueOf(c.Handler()).Pointer() == notFoundHandler {
+		return TelemetryUnmatchedPath
+	}
+	return path
+}
 
-	reqDur := collector.NewHistogramVec(
-		collector.HistogramOpts{
-			Name: collector.Name(subsystem, "request", "duration", "seconds"),
-			Help: "The HTTP request latencies in seconds.",
-		},
-		[]string{"code", "method", "path"},
-	)
+func telemetryMethod(method string) string {
+	if _, ok := telemetryMethods[method]; ok {
+		return method
+	}
+	return "OTHER"
+}
 
-	reqSz := collector.NewHistogramVec(
-		collector.HistogramOpts{
-			Name: collector.Name(subsystem, "request", "size", "bytes"),
-			Help: "The HTTP request sizes in bytes.",
-		},
-		[]string{"code", "method", "path"},
-	)
+// responseStatus returns status code of the response,
+// error handler writes response after middleware chain, so status is taken from error.
+func responseStatus(c echo.Context, err error) int {
+	if err != nil && !c.Response().Committed {
+		return serverErrors.From(err).Code
+	}
+	return c.Response().Status
+}
 
-	resSz := collector.NewHistogramVec(
-		collector.HistogramOpts{
-			Name: collector.Name(subsystem, "response", "size", "bytes"),
-			Help: "The HTTP response sizes in bytes.",
-		},
-		[]string{"code", "method", "path"},
-	)
+func telemetryLabelValues(c echo.Context, labels []string, err error) []string {
+	status := responseStatus(c, err)
+
+	values := make([]string, len(labels))
+	for n, label := range labels {
+		switch label {
+		case TelemetryLabelCode:
+			values[n] = strconv.Itoa(status)
+		case TelemetryLabelStatusClass:
+			values[n] = strconv.Itoa(status/100) + "xx"
+		case TelemetryLabelMethod:
+			values[n] = telemetryMethod(c.Request().Method)
+		case TelemetryLabelPath:
+			values[n] = telemetryPath(c)
+		}
+	}
+	return values
+}
 
-	//
+func NewTelemetry(r *registry.Registry, subsystem string, c TelemetryConfig) echo.MiddlewareFunc {
+	f := registry.NewFactory(r, subsystem)
 
-	r.MustRegister(reqTot)
-	r.MustRegister(reqDur)
-	r.MustRegister(reqSz)
-	r.MustRegister(resSz)
+	reqTot := f.CounterVec(
+		"requests_total",
+		"How many HTTP requests processed, partitioned by configured labels (status code, HTTP method and route by default).",
+		c.Labels...,
+	)
+	reqDur := f.HistogramVec(
+		"request_duration_seconds",
+		"The HTTP request latencies in seconds.",
+		c.DurationBuckets, c.Labels...,
+	)
+	reqSz := f.HistogramVec(
+		"request_size_bytes",
+		"The HTTP request sizes in bytes.",
+		c.SizeBuckets, c.Labels...,
+	)
+	resSz := f.HistogramVec(
+		"response_size_bytes",
+		"The HTTP response sizes in bytes.",
+		c.SizeBuckets, c.Labels...,
+	)
+	inFlight := f.Gauge(
+		"requests_in_flight",
+		"How many HTTP requests are being processed.",
+	)
 
 	//
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
+		return func(ctx echo.Context) error {
 			start := time.Now()
-			req := c.Request()
-			reqSize := telemetryApproxRequestSize(req)
+			reqSize := telemetryApproxRequestSize(ctx.Request())
+
+			inFlight.Inc()
+			defer inFlight.Dec()
 
 			//
 
-			err := next(c)
+			err := next(ctx)
 			// continue on error to count metrics
 
-			status := strconv.Itoa(c.Response().Status)
-			path := req.URL.Path
+			values := telemetryLabelValues(ctx, c.Labels, err)
 			elapsed := float64(time.Since(start)) / float64(time.Second)
-			resSize := float64(c.Response().Size)
+			resSize := float64(ctx.Response().Size)
 
 			//
 
-			reqTot.WithLabelValues(status, c.Request().Method, path).Inc()
-			reqDur.WithLabelValues(status, c.Request().Method, path).Observe(elapsed)
-			reqSz.WithLabelValues(status, c.Request().Method, path).Observe(float64(reqSize))
-			resSz.WithLabelValues(status, c.Request().Method, path).Observe(resSize)
+			reqTot.WithLabelValues(values...).Inc()
+			if sc := trace.SpanContextFromContext(ctx.Request().Context()); sc.IsSampled() {
+				// exemplars link histograms to traces, they are exposed with OpenMetrics format
+				exemplar := collector.Labels{"trace_id": sc.TraceID.String()}
+				reqDur.WithLabelValues(values...).(collector.ExemplarObserver).ObserveWithExemplar(elapsed, exemplar)
+				reqSz.WithLabelValues(values...).(collector.ExemplarObserver).ObserveWithExemplar(float64(reqSize), exemplar)
+				resSz.WithLabelValues(values...).(collector.ExemplarObserver).ObserveWithExemplar(resSize, exemplar)
+			} else {
+				reqDur.WithLabelValues(values...).Observe(elapsed)
+				reqSz.WithLabelValues(values...).Observe(float64(reqSize))
+				resSz.WithLabelValues(values...).Observe(resSize)
+			}
 
 			return err
 		}
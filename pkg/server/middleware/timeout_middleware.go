@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+)
+
+// NewTimeout cancels request context after timeout,
+// handlers are expected to respect Request().Context().
+func NewTimeout(timeout time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			defer cancel()
+
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if ctx.Err() == context.DeadlineExceeded && !c.Response().Committed {
+				return serverErrors.NewError(
+					http.StatusServiceUnavailable, "request handling timed out",
+					err, map[string]string{"timeout": timeout.String()},
+				).WithKind(serverErrors.KindTimeout)
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/trace"
+)
+
+// NewTrace starts server span for each request, continuing trace from
+// W3C traceparent header, span is available with trace.SpanFromContext(c.Request().Context()).
+// Span is named after matched route, so it should go before other middleware
+// to include their time and to make trace ids available to logger.
+func NewTrace(t *trace.Tracer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			ctx := req.Context()
+			if sc, ok := trace.Extract(req.Header); ok {
+				ctx = trace.ContextWithRemoteSpanContext(ctx, sc)
+			}
+			ctx, span := t.Start(ctx, req.Method, trace.SpanKindServer)
+			defer span.End()
+
+			c.SetRequest(req.WithContext(ctx))
+
+			span.SetAttribute("http.method", req.Method)
+			span.SetAttribute("http.target", req.URL.Path)
+			span.SetAttribute("http.host", req.Host)
+			span.SetAttribute("http.client_ip", c.RealIP())
+			span.SetAttribute("http.user_agent", req.UserAgent())
+			if id := c.Response().Header().Get(echo.HeaderXRequestID); id != "" {
+				span.SetAttribute("http.request_id", id)
+			}
+
+			err := next(c)
+
+			path := telemetryPath(c)
+			status := responseStatus(c, err)
+			span.SetName(telemetryMethod(req.Method) + " " + path)
+			span.SetAttribute("http.route", path)
+			span.SetAttribute("http.status_code", status)
+			// client errors are not server span failures
+			if status >= http.StatusInternalServerError {
+				if err != nil {
+					span.RecordError(err)
+				} else {
+					span.SetStatus(trace.StatusError, http.StatusText(status))
+				}
+			}
+
+			return err
+		}
+	}
+}
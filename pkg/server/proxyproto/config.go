@@ -0,0 +1,44 @@
+package proxyproto
+
+import (
+	"net"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+type Config struct {
+	Enable bool `yaml:"enable"`
+	// TrustCIDR is a list of peer ranges allowed to send PROXY protocol header,
+	// header from other peers is not parsed.
+	TrustCIDR []string `yaml:"trust-cidr"`
+	// Required makes connections from trusted peers without header fail.
+	Required bool `yaml:"required"`
+	// Timeout limits time to read PROXY protocol header.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.Timeout <= 0:
+			c.Timeout = 5 * time.Second
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.Enable && len(c.TrustCIDR) == 0 {
+		return errors.New("trust-cidr should not be empty when proxy protocol is enabled")
+	}
+	for _, cidr := range c.TrustCIDR {
+		_, _, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse trusted cidr %q", cidr)
+		}
+	}
+	return nil
+}
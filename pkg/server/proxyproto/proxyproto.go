@@ -0,0 +1,306 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+// see: https://www.haproxy.org/download/2.4/doc/proxy-protocol.txt
+
+const (
+	v1Prefix    = "PROXY "
+	v1MaxLength = 107
+
+	v2HeaderLength = 16
+	v2Version      = 0x2
+	v2CommandLocal = 0x0
+	v2CommandProxy = 0x1
+	v2FamilyInet   = 0x1
+	v2FamilyInet6  = 0x2
+)
+
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+//
+
+type Listener struct {
+	net.Listener
+	config  Config
+	trusted []*net.IPNet
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{
+		Conn:     conn,
+		reader:   conn,
+		trusted:  l.trust(conn.RemoteAddr()),
+		required: l.config.Required,
+		timeout:  l.config.Timeout,
+		once:     &sync.Once{},
+	}, nil
+}
+
+func (l *Listener) trust(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, ipnet := range l.trusted {
+		if ipnet.Contains(tcp.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+//
+
+// Conn reads PROXY protocol header lazily on first Read/RemoteAddr/LocalAddr call,
+// so slow peers could not block Accept loop.
+type Conn struct {
+	net.Conn
+	reader   io.Reader
+	trusted  bool
+	required bool
+	timeout  time.Duration
+
+	once   *sync.Once
+	err    error
+	remote net.Addr
+	local  net.Addr
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *Conn) readHeader() {
+	if !c.trusted {
+		return
+	}
+
+	err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	if err != nil {
+		c.err = err
+		return
+	}
+	defer c.Conn.SetReadDeadline(time.Time{}) // nolint: errcheck
+
+	r := bufio.NewReaderSize(c.Conn, v1MaxLength+1)
+	c.reader = r
+
+	version, err := detectVersion(r)
+	if err != nil {
+		c.err = errors.Wrap(err, "failed to read proxy protocol header")
+		return
+	}
+
+	switch version {
+	case 1:
+		c.remote, c.local, c.err = readV1(r)
+	case 2:
+		c.remote, c.local, c.err = readV2(r)
+	default:
+		if c.required {
+			c.err = errors.Errorf(
+				"proxy protocol header is required for peer %s",
+				c.Conn.RemoteAddr(),
+			)
+		}
+	}
+}
+
+//
+
+// detectVersion peeks bytes until they match v1 prefix or v2 signature,
+// returning 0 as soon as they match neither, so requests without header
+// (like POST which starts with the same byte as v1 prefix) are left unread
+// and short requests are not blocked waiting for more bytes.
+func detectVersion(r *bufio.Reader) (int, error) {
+	v1, v2 := true, true
+	for n := 1; ; n++ {
+		buf, err := r.Peek(n)
+		if err != nil {
+			if n > 1 && err == io.EOF {
+				// peer sent less bytes than header, it is not a header
+				return 0, nil
+			}
+			return 0, err
+		}
+		b := buf[n-1]
+		v1 = v1 && n <= len(v1Prefix) && b == v1Prefix[n-1]
+		v2 = v2 && n <= len(v2Signature) && b == v2Signature[n-1]
+
+		switch {
+		case v1 && n == len(v1Prefix):
+			return 1, nil
+		case v2 && n == len(v2Signature):
+			return 2, nil
+		case !v1 && !v2:
+			return 0, nil
+		}
+	}
+}
+
+func readV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	buf, err := r.Peek(len(v1Prefix))
+	if err != nil || string(buf) != v1Prefix {
+		return nil, nil, errors.New("malformed proxy protocol v1 header prefix")
+	}
+
+	line := make([]byte, 0, v1MaxLength)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to read proxy protocol v1 header")
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= v1MaxLength {
+			return nil, nil, errors.New("proxy protocol v1 header is too long")
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errors.New("malformed proxy protocol v1 header line ending")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, errors.Errorf("malformed proxy protocol v1 header %q", line)
+	}
+
+	src, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return src, dst, nil
+}
+
+func parseV1Addr(host string, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, errors.Errorf("malformed proxy protocol v1 address %q", host)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, errors.Wrapf(err, "malformed proxy protocol v1 port %q", port)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+func readV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, v2HeaderLength)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read proxy protocol v2 header")
+	}
+	if !bytes.Equal(header[:len(v2Signature)], v2Signature) {
+		return nil, nil, errors.New("malformed proxy protocol v2 signature")
+	}
+	if header[12]>>4 != v2Version {
+		return nil, nil, errors.Errorf("unsupported proxy protocol version %d", header[12]>>4)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read proxy protocol v2 addresses")
+	}
+
+	switch header[12] & 0xf {
+	case v2CommandLocal:
+		// health checks from the proxy itself, keep original addresses
+		return nil, nil, nil
+	case v2CommandProxy:
+	default:
+		return nil, nil, errors.Errorf("unsupported proxy protocol v2 command %d", header[12]&0xf)
+	}
+
+	var size int
+	switch header[13] >> 4 {
+	case v2FamilyInet:
+		size = net.IPv4len
+	case v2FamilyInet6:
+		size = net.IPv6len
+	default:
+		// unix sockets and unspecified families carry no usable address
+		return nil, nil, nil
+	}
+	if len(payload) < 2*size+4 {
+		return nil, nil, errors.New("proxy protocol v2 address block is too short")
+	}
+
+	src := &net.TCPAddr{
+		IP:   net.IP(payload[:size]),
+		Port: int(binary.BigEndian.Uint16(payload[2*size:])),
+	}
+	dst := &net.TCPAddr{
+		IP:   net.IP(payload[size : 2*size]),
+		Port: int(binary.BigEndian.Uint16(payload[2*size+2:])),
+	}
+
+	return src, dst, nil
+}
+
+//
+
+func New(c Config, l net.Listener) (*Listener, error) {
+	trusted := make([]*net.IPNet, len(c.TrustCIDR))
+	for n, cidr := range c.TrustCIDR {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		trusted[n] = ipnet
+	}
+
+	return &Listener{
+		Listener: l,
+		config:   c,
+		trusted:  trusted,
+	}, nil
+}
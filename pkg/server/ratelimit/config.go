@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+type Config struct {
+	Enable bool `yaml:"enable"`
+	// Policy is applied to all routes of the server,
+	// it runs before application middleware, so session key is not available here.
+	Policy *PolicyConfig `yaml:"policy"`
+	// Groups maps route group prefix to the policy applied to this group
+	// (session key requires session middleware to be registered before the group).
+	Groups map[string]*PolicyConfig `yaml:"groups"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.Policy == nil:
+			c.Policy = &PolicyConfig{}
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.Policy.Key == KeyNameSession {
+		return errors.Errorf(
+			"policy key %q is not supported because policy runs before session middleware, use it in groups",
+			KeyNameSession,
+		)
+	}
+	for prefix, policy := range c.Groups {
+		if policy == nil {
+			return errors.Errorf("group %q policy should not be empty", prefix)
+		}
+	}
+	return nil
+}
+
+//
+
+type PolicyConfig struct {
+	// Limit is a number of requests allowed per Period.
+	Limit  int           `yaml:"limit"`
+	Period time.Duration `yaml:"period"`
+	// Burst is a number of requests which could be made at once.
+	Burst int `yaml:"burst"`
+	// Key is a name of the key extractor, see Keys.
+	Key string `yaml:"key"`
+}
+
+func (c *PolicyConfig) Default() {
+loop:
+	for {
+		switch {
+		case c.Limit <= 0:
+			c.Limit = 100
+		case c.Period <= 0:
+			c.Period = time.Second
+		case c.Burst <= 0:
+			c.Burst = c.Limit
+		case c.Key == "":
+			c.Key = KeyNameIP
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *PolicyConfig) Validate() error {
+	if c.Limit <= 0 {
+		return errors.Errorf("limit should be greater than zero, got %d", c.Limit)
+	}
+	interval := c.Period / time.Duration(c.Limit)
+	if interval <= 0 {
+		return errors.Errorf(
+			"period %s divided by limit %d should be greater than zero, decrease limit or increase period",
+			c.Period, c.Limit,
+		)
+	}
+	// burst tolerance (interval * burst) should fit into duration
+	if int64(c.Burst) > math.MaxInt64/int64(interval) {
+		return errors.Errorf("burst %d is too large for interval %s", c.Burst, interval)
+	}
+	if _, ok := Keys[c.Key]; !ok {
+		available := make([]string, 0, len(Keys))
+		for k := range Keys {
+			available = append(available, k)
+		}
+		sort.Strings(available)
+
+		return errors.Errorf(
+			"unexpected key %q, expected one of: %q",
+			c.Key, available,
+		)
+	}
+	return nil
+}
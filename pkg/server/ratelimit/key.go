@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/server/session"
+)
+
+const (
+	KeyNameIP      = "ip"
+	KeyNameSession = "session"
+	KeyNameRoute   = "route"
+)
+
+// KeyFunc extracts rate limiting key from the request.
+type KeyFunc = func(echo.Context) (string, error)
+
+// Keys is a registry of key extractors available to the configuration,
+// applications could register their own extractors here.
+var Keys = map[string]KeyFunc{
+	KeyNameIP:      KeyIP,
+	KeyNameSession: KeySession,
+	KeyNameRoute:   KeyRoute,
+}
+
+// KeyIP uses client IP address as a key.
+func KeyIP(c echo.Context) (string, error) {
+	return c.RealIP(), nil
+}
+
+// KeySession uses session identifier as a key,
+// falling back to client IP address if there is no session or session has no identifier.
+// Identifier is never created here, otherwise client which drops cookies
+// would get a new bucket on each request.
+// Session middleware should be registered before middleware which uses this key.
+func KeySession(c echo.Context) (string, error) {
+	store, ok := session.GetStore(c)
+	if !ok {
+		return KeyIP(c)
+	}
+	id, ok := store.Session().LookupID()
+	if !ok {
+		return KeyIP(c)
+	}
+	return id, nil
+}
+
+// KeyRoute uses matched route and client IP address as a key,
+// so each route is limited independently.
+func KeyRoute(c echo.Context) (string, error) {
+	return c.Request().Method + " " + c.Path() + " " + c.RealIP(), nil
+}
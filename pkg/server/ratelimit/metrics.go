@@ -0,0 +1,21 @@
+package ratelimit
+
+import (
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+)
+
+type Metrics struct {
+	Requests *collector.CounterVec
+}
+
+func NewMetrics(r *registry.Registry, subsystem string) *Metrics {
+	f := registry.NewFactory(r, subsystem)
+	return &Metrics{
+		Requests: f.CounterVec(
+			"ratelimit_requests_total",
+			"How many requests passed through rate limiter, partitioned by policy and result.",
+			"policy", "result",
+		),
+	}
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+// maxSwapAttempts is a number of compare and swap attempts made under contention.
+const maxSwapAttempts = 10
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter implements GCRA (generic cell rate algorithm).
+type Limiter struct {
+	name     string
+	config   PolicyConfig
+	store    Store
+	interval time.Duration
+	tau      time.Duration
+}
+
+func (l *Limiter) Name() string            { return l.name }
+func (l *Limiter) Config() PolicyConfig    { return l.config }
+func (l *Limiter) KeyFunc() KeyFunc        { return Keys[l.config.Key] }
+func (l *Limiter) Interval() time.Duration { return l.interval }
+
+// Take accounts one request for the key.
+func (l *Limiter) Take(key string) (Result, error) {
+	key = l.name + ":" + key
+
+	for n := 0; n < maxSwapAttempts; n++ {
+		now := time.Now()
+
+		stored, ok, err := l.store.Get(key)
+		if err != nil {
+			return Result{}, errors.Wrapf(err, "failed to get rate limit state for key %q", key)
+		}
+
+		tat := stored
+		if !ok || tat.Before(now) {
+			tat = now
+		}
+
+		newTat := tat.Add(l.interval)
+		allowAt := newTat.Add(-l.tau)
+		if now.Before(allowAt) {
+			return Result{
+				Allowed:    false,
+				Limit:      l.config.Burst,
+				Remaining:  0,
+				ResetAfter: tat.Sub(now),
+				RetryAfter: allowAt.Sub(now),
+			}, nil
+		}
+
+		swapped, err := l.store.CompareAndSwap(key, stored, newTat, newTat.Sub(now))
+		if err != nil {
+			return Result{}, errors.Wrapf(err, "failed to update rate limit state for key %q", key)
+		}
+		if !swapped {
+			continue
+		}
+
+		return Result{
+			Allowed:    true,
+			Limit:      l.config.Burst,
+			Remaining:  int(now.Sub(allowAt) / l.interval),
+			ResetAfter: newTat.Sub(now),
+		}, nil
+	}
+
+	return Result{}, errors.Errorf(
+		"failed to update rate limit state for key %q after %d attempts",
+		key, maxSwapAttempts,
+	)
+}
+
+func New(name string, c PolicyConfig, s Store) *Limiter {
+	c.Default()
+
+	interval := c.Period / time.Duration(c.Limit)
+	return &Limiter{
+		name:     name,
+		config:   c,
+		store:    s,
+		interval: interval,
+		tau:      interval * time.Duration(c.Burst),
+	}
+}
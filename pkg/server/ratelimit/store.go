@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Store keeps theoretical arrival time (TAT) of the next request for each key.
+type Store interface {
+	// Get returns TAT for the key, false is returned if key is missing.
+	Get(key string) (time.Time, bool, error)
+	// CompareAndSwap sets TAT for the key if current value equals to old
+	// (missing key is represented by zero time), value expires after ttl.
+	CompareAndSwap(key string, old time.Time, new time.Time, ttl time.Duration) (bool, error)
+}
+
+//
+
+type memoryStoreEntry struct {
+	tat     time.Time
+	expires time.Time
+}
+
+var _ Store = new(MemoryStore)
+
+// MemoryStore keeps keys in memory, expired keys are swept periodically.
+type MemoryStore struct {
+	lock      *sync.Mutex
+	entries   map[string]memoryStoreEntry
+	sweep     time.Duration
+	lastSweep time.Time
+}
+
+func (s *MemoryStore) Get(key string) (time.Time, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || !e.expires.After(time.Now()) {
+		return time.Time{}, false, nil
+	}
+	return e.tat, true, nil
+}
+
+func (s *MemoryStore) CompareAndSwap(key string, old time.Time, new time.Time, ttl time.Duration) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.sweepExpired(now)
+
+	var current time.Time
+	e, ok := s.entries[key]
+	if ok && e.expires.After(now) {
+		current = e.tat
+	}
+	if !current.Equal(old) {
+		return false, nil
+	}
+
+	s.entries[key] = memoryStoreEntry{tat: new, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *MemoryStore) sweepExpired(now time.Time) {
+	if now.Sub(s.lastSweep) < s.sweep {
+		return
+	}
+	for k, e := range s.entries {
+		if !e.expires.After(now) {
+			delete(s.entries, k)
+		}
+	}
+	s.lastSweep = now
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		lock:      &sync.Mutex{},
+		entries:   map[string]memoryStoreEntry{},
+		sweep:     time.Minute,
+		lastSweep: time.Now(),
+	}
+}
@@ -0,0 +1,140 @@
+package response
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderETag        = "ETag"
+	HeaderIfNoneMatch = "If-None-Match"
+
+	etagSize = 16
+)
+
+// SetLastModified sets Last-Modified validator which is compared with If-Modified-Since,
+// when it is set before finalizer is dispatched rendering is skipped for not modified responses.
+func SetLastModified(ctx Context, t time.Time) {
+	ctx.Response().Header().Set(echo.HeaderLastModified, t.UTC().Format(http.TimeFormat))
+}
+
+// SetETag sets entity tag instead of the one computed from response body,
+// like SetLastModified it allows to skip rendering.
+func SetETag(ctx Context, tag string, weak bool) {
+	etag := `"` + tag + `"`
+	if weak {
+		etag = "W/" + etag
+	}
+	ctx.Response().Header().Set(HeaderETag, etag)
+}
+
+//
+
+// conditionalWriter buffers response so ETag could be computed from body.
+type conditionalWriter struct {
+	http.ResponseWriter
+	code int
+	buf  bytes.Buffer
+}
+
+func (w *conditionalWriter) WriteHeader(code int)        { w.code = code }
+func (w *conditionalWriter) Write(b []byte) (int, error) { return w.buf.Write(b) }
+
+// cacheable reports whether finalizer produces a complete representation
+// which could be validated with ETag (streams and redirects could not).
+func cacheable(f Finalizer) bool {
+	switch f.(type) {
+	case *JSONFinalizer, *HTMLFinalizer, *HTMLBlobFinalizer,
+		*StringFinalizer, *TemplateFinalizer, *DataFinalizer:
+		return true
+	default:
+		return false
+	}
+}
+
+// DispatchConditional dispatches finalizer handling request preconditions
+// (RFC 7232) for GET and HEAD requests: ETag is computed from response body
+// unless set with SetETag, 304 Not Modified is sent when If-None-Match
+// or If-Modified-Since (compared with Last-Modified set by SetLastModified) match.
+func DispatchConditional(ctx Context, f Finalizer, options ...FinalizerDispatchOption) error {
+	req := ctx.Request()
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || !cacheable(f) {
+		return DispatchFinalizer(ctx, f, options...)
+	}
+
+	res := ctx.Response()
+	if notModified(req, res.Header()) {
+		res.Header().Del(echo.HeaderContentType)
+		res.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w := &conditionalWriter{ResponseWriter: res.Writer}
+	res.Writer = w
+	err := DispatchFinalizer(ctx, f, options...)
+	res.Writer = w.ResponseWriter
+	if err != nil || w.code == 0 {
+		return err
+	}
+
+	h := res.Header()
+	if w.code == http.StatusOK {
+		if h.Get(HeaderETag) == "" {
+			sum := sha256.Sum256(w.buf.Bytes())
+			h.Set(HeaderETag, `"`+base64.RawURLEncoding.EncodeToString(sum[:etagSize])+`"`)
+		}
+		if notModified(req, h) {
+			h.Del(echo.HeaderContentType)
+			h.Del(echo.HeaderContentLength)
+			res.Status = http.StatusNotModified
+			res.Size = 0
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.code)
+	_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	return err
+}
+
+// notModified evaluates If-None-Match (which takes precedence) or If-Modified-Since.
+func notModified(req *http.Request, h http.Header) bool {
+	if inm := req.Header.Get(HeaderIfNoneMatch); inm != "" {
+		etag := h.Get(HeaderETag)
+		return etag != "" && etagMatch(inm, etag)
+	}
+
+	ims, lm := req.Header.Get(echo.HeaderIfModifiedSince), h.Get(echo.HeaderLastModified)
+	if ims == "" || lm == "" {
+		return false
+	}
+	imsTime, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	lmTime, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return !lmTime.After(imsTime)
+}
+
+// etagMatch uses weak comparison, so compressed representations
+// (which have weak tags) match tags of the original.
+func etagMatch(inm string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(inm, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
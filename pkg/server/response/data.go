@@ -0,0 +1,288 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	echo "github.com/labstack/echo/v4"
+	msgpack "github.com/vmihailenco/msgpack/v5"
+	yaml "gopkg.in/yaml.v2"
+
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+)
+
+const (
+	MIMEApplicationYAML = "application/yaml"
+
+	KindNotAcceptable = "not_acceptable"
+)
+
+type (
+	// Codec encodes DataFinalizer payload into some media type.
+	// Additional codecs (like CBOR) could be plugged in with WithCodecs.
+	Codec struct {
+		MediaType   string
+		Aliases     []string
+		ContentType string
+		Marshal     func(v interface{}) ([]byte, error)
+	}
+
+	acceptRange struct {
+		mediaType string
+		q         float64
+	}
+)
+
+var (
+	CodecJSON = Codec{
+		MediaType:   echo.MIMEApplicationJSON,
+		ContentType: echo.MIMEApplicationJSONCharsetUTF8,
+		Marshal:     json.Marshal,
+	}
+	CodecMsgpack = Codec{
+		MediaType:   echo.MIMEApplicationMsgpack,
+		Aliases:     []string{"application/x-msgpack", "application/vnd.msgpack"},
+		ContentType: echo.MIMEApplicationMsgpack,
+		Marshal:     marshalMsgpack,
+	}
+	CodecYAML = Codec{
+		MediaType:   MIMEApplicationYAML,
+		Aliases:     []string{"application/x-yaml", "text/yaml"},
+		ContentType: MIMEApplicationYAML,
+		Marshal:     marshalYAML,
+	}
+
+	// DefaultCodecs are used when no WithCodecs option given,
+	// first codec is used when client accepts anything.
+	DefaultCodecs = []Codec{CodecJSON, CodecMsgpack, CodecYAML}
+)
+
+// marshalMsgpack uses json tags, so msgpack documents have same keys as JSON.
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalYAML encodes JSON representation of the value,
+// so YAML documents have same keys (and key order) as JSON.
+func marshalYAML(v interface{}) ([]byte, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+
+	vv, err := decodeYAMLValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(vv)
+}
+
+// decodeYAMLValue decodes next JSON value into the value for yaml.Marshal,
+// objects are decoded into yaml.MapSlice to keep key order.
+func decodeYAMLValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			m := yaml.MapSlice{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeYAMLValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				m = append(m, yaml.MapItem{Key: key, Value: value})
+			}
+			_, err = dec.Token() // }
+			return m, err
+		default: // [
+			xs := []interface{}{}
+			for dec.More() {
+				value, err := decodeYAMLValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				xs = append(xs, value)
+			}
+			_, err = dec.Token() // ]
+			return xs, err
+		}
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n, nil
+		}
+		return t.Float64()
+	default:
+		return t, nil
+	}
+}
+
+//
+
+type DataFinalizer struct {
+	Code int
+	Data interface{}
+}
+
+// Data responds with data encoded into media type negotiated with Accept header.
+func Data(code int, data interface{}) *DataFinalizer {
+	return &DataFinalizer{Code: code, Data: data}
+}
+
+// WithCodecs sets codecs used to encode DataFinalizer, first codec is a default.
+func WithCodecs(codecs ...Codec) FinalizerDispatchOption {
+	return func(ctx Context, f Finalizer) (bool, error) {
+		d, ok := f.(*DataFinalizer)
+		if !ok {
+			return false, nil
+		}
+		return true, dispatchData(ctx, d, codecs)
+	}
+}
+
+func dispatchData(ctx Context, d *DataFinalizer, codecs []Codec) error {
+	AddVary(ctx.Response().Header(), echo.HeaderAccept)
+
+	codec, ok := Negotiate(ctx.Request().Header.Get(echo.HeaderAccept), codecs)
+	if !ok {
+		available := make([]string, len(codecs))
+		for n, c := range codecs {
+			available[n] = c.MediaType
+		}
+		return serverErrors.NewError(
+			http.StatusNotAcceptable, "",
+			nil, map[string][]string{"available": available},
+		).WithKind(KindNotAcceptable)
+	}
+
+	buf, err := codec.Marshal(d.Data)
+	if err != nil {
+		return err
+	}
+	return ctx.Blob(d.Code, codec.ContentType, buf)
+}
+
+// AddVary adds header name to Vary unless it is already listed.
+func AddVary(h http.Header, name string) {
+	for _, vary := range h.Values(echo.HeaderVary) {
+		for _, v := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), name) {
+				return
+			}
+		}
+	}
+	h.Add(echo.HeaderVary, name)
+}
+
+//
+
+// Negotiate picks codec for Accept header value (RFC 7231 section 5.3.2),
+// empty header accepts first codec, on equal quality earlier codec wins.
+func Negotiate(accept string, codecs []Codec) (Codec, bool) {
+	if len(codecs) == 0 {
+		return Codec{}, false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return codecs[0], true
+	}
+
+	ranges := parseAccept(accept)
+
+	var (
+		best  Codec
+		bestQ float64
+	)
+	for _, codec := range codecs {
+		q := codecQuality(codec, ranges)
+		if q > bestQ {
+			best, bestQ = codec, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+func parseAccept(accept string) []acceptRange {
+	ranges := []acceptRange{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qv, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(qv, 64)
+			if err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+
+	// more specific ranges take precedence
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return acceptSpecificity(ranges[i].mediaType) > acceptSpecificity(ranges[j].mediaType)
+	})
+	return ranges
+}
+
+func acceptSpecificity(mediaType string) int {
+	switch {
+	case mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(mediaType, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func codecQuality(codec Codec, ranges []acceptRange) float64 {
+	var q float64
+	for _, mediaType := range append([]string{codec.MediaType}, codec.Aliases...) {
+		for _, r := range ranges {
+			if acceptMatch(r.mediaType, mediaType) {
+				if r.q > q {
+					q = r.q
+				}
+				break // most specific range decides
+			}
+		}
+	}
+	return q
+}
+
+func acceptMatch(pattern string, mediaType string) bool {
+	switch {
+	case pattern == "*/*":
+		return true
+	case strings.HasSuffix(pattern, "/*"):
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	default:
+		return pattern == mediaType
+	}
+}
@@ -55,6 +55,14 @@ func DispatchFinalizer(ctx Context, r Finalizer, options ...FinalizerDispatchOpt
 		return ctx.HTMLBlob(rr.Code, rr.HTML)
 	case *StringFinalizer:
 		return ctx.String(rr.Code, rr.String)
+	case *TemplateFinalizer:
+		return ctx.Render(rr.Code, rr.Name, rr.Data)
+	case *DataFinalizer:
+		return dispatchData(ctx, rr, DefaultCodecs)
+	case *SSEFinalizer:
+		return dispatchSSE(ctx, rr)
+	case *NDJSONFinalizer:
+		return dispatchNDJSON(ctx, rr)
 	default:
 		return errors.Errorf("failed to dispatch response type %T, no match", r)
 	}
@@ -62,3 +62,15 @@ type StringFinalizer struct {
 func String(code int, s string) *StringFinalizer {
 	return &StringFinalizer{Code: code, String: s}
 }
+
+//
+
+type TemplateFinalizer struct {
+	Code int
+	Name string
+	Data interface{}
+}
+
+func Template(code int, name string, data interface{}) *TemplateFinalizer {
+	return &TemplateFinalizer{Code: code, Name: name, Data: data}
+}
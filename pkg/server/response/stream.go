@@ -0,0 +1,199 @@
+package response
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+// NOTE: streams are written inside handler, so logger and telemetry middleware
+// record byte counts and durations when stream ends.
+// Server write timeout (and handler timeout) apply to streams too,
+// so servers with long-living streams should relax them.
+
+const (
+	MIMETextEventStream   = "text/event-stream"
+	MIMEApplicationNDJSON = "application/x-ndjson"
+
+	HeaderLastEventID = "Last-Event-ID"
+	QueryLastEventID  = "lastEventId"
+
+	DefaultSSEHeartbeat = 15 * time.Second
+
+	sseHeartbeatComment   = ": heartbeat\n\n"
+	headerXAccelBuffering = "X-Accel-Buffering"
+)
+
+// sseLineEndings are normalized in event data, clients treat each of them as line end.
+var sseLineEndings = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// Event is a Server-Sent Event,
+// Data is written as is when it is a string or []byte, otherwise encoded as JSON.
+// ID and Event should not contain line breaks, otherwise event is not written.
+type Event struct {
+	ID    string
+	Event string
+	Data  interface{}
+	// Retry tells client how long to wait before reconnect.
+	Retry time.Duration
+}
+
+func (e Event) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	if strings.ContainsAny(e.ID, "\r\n") {
+		return 0, errors.Errorf("event id %q should not contain line breaks", e.ID)
+	}
+	if strings.ContainsAny(e.Event, "\r\n") {
+		return 0, errors.Errorf("event type %q should not contain line breaks", e.Event)
+	}
+
+	if e.ID != "" {
+		b.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	var data string
+	switch v := e.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return 0, err
+		}
+		data = string(buf)
+	}
+	for _, line := range strings.Split(sseLineEndings.Replace(data), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// LastEventID returns ID of the last event received by reconnecting client,
+// taken from Last-Event-ID header or lastEventId query parameter
+// (for clients which could not set headers).
+func LastEventID(ctx Context) string {
+	id := ctx.Request().Header.Get(HeaderLastEventID)
+	if id == "" {
+		id = ctx.QueryParam(QueryLastEventID)
+	}
+	return id
+}
+
+//
+
+type SSEFinalizer struct {
+	Events <-chan Event
+	// Heartbeat is an interval of comments sent to keep connection alive,
+	// zero disables heartbeats.
+	Heartbeat time.Duration
+}
+
+// SSE streams events from channel until it is closed or client disconnects.
+// Use LastEventID to resume stream after reconnect.
+func SSE(events <-chan Event) *SSEFinalizer {
+	return &SSEFinalizer{Events: events, Heartbeat: DefaultSSEHeartbeat}
+}
+
+type NDJSONFinalizer struct {
+	Code  int
+	Items <-chan interface{}
+}
+
+// NDJSON streams items from channel as newline-delimited JSON
+// until channel is closed or client disconnects.
+func NDJSON(code int, items <-chan interface{}) *NDJSONFinalizer {
+	return &NDJSONFinalizer{Code: code, Items: items}
+}
+
+//
+
+func flush(ctx Context) {
+	if f, ok := ctx.Response().Writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func dispatchSSE(ctx Context, f *SSEFinalizer) error {
+	res := ctx.Response()
+	h := res.Header()
+	h.Set(echo.HeaderContentType, MIMETextEventStream)
+	h.Set("Cache-Control", "no-cache")
+	h.Set(headerXAccelBuffering, "no")
+	res.WriteHeader(http.StatusOK)
+	flush(ctx)
+
+	var heartbeat <-chan time.Time
+	if f.Heartbeat > 0 {
+		ticker := time.NewTicker(f.Heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	done := ctx.Request().Context().Done()
+	for {
+		select {
+		case <-done:
+			return nil // client disconnected
+		case <-heartbeat:
+			_, err := io.WriteString(res, sseHeartbeatComment)
+			if err != nil {
+				return err
+			}
+		case evt, ok := <-f.Events:
+			if !ok {
+				return nil
+			}
+			_, err := evt.WriteTo(res)
+			if err != nil {
+				return errors.Wrapf(err, "failed to write event %q", evt.ID)
+			}
+		}
+		flush(ctx)
+	}
+}
+
+func dispatchNDJSON(ctx Context, f *NDJSONFinalizer) error {
+	res := ctx.Response()
+	res.Header().Set(echo.HeaderContentType, MIMEApplicationNDJSON)
+	res.Header().Set(headerXAccelBuffering, "no")
+	res.WriteHeader(f.Code)
+	flush(ctx)
+
+	enc := json.NewEncoder(res) // encoder terminates each value with newline
+	done := ctx.Request().Context().Done()
+	for {
+		select {
+		case <-done:
+			return nil // client disconnected
+		case item, ok := <-f.Items:
+			if !ok {
+				return nil
+			}
+			err := enc.Encode(item)
+			if err != nil {
+				return err
+			}
+			flush(ctx)
+		}
+	}
+}
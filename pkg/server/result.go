@@ -2,6 +2,8 @@ package server
 
 import (
 	"net/http"
+
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
 )
 
 type (
@@ -12,11 +14,30 @@ type (
 	}
 
 	ResultError struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
+		Code      int          `json:"code"`
+		Kind      string       `json:"kind"`
+		Message   string       `json:"message"`
+		RequestID string       `json:"request_id,omitempty"`
+		Fields    []FieldError `json:"fields,omitempty"`
+		Meta      interface{}  `json:"meta,omitempty"`
+	}
+
+	// ProblemResult is a RFC 7807 problem details object,
+	// returned when client accepts application/problem+json.
+	ProblemResult struct {
+		Type      string       `json:"type"`
+		Title     string       `json:"title"`
+		Status    int          `json:"status"`
+		Detail    string       `json:"detail,omitempty"`
+		Instance  string       `json:"instance,omitempty"`
+		Kind      string       `json:"kind"`
+		RequestID string       `json:"request_id,omitempty"`
+		Fields    []FieldError `json:"fields,omitempty"`
+		Meta      interface{}  `json:"meta,omitempty"`
 	}
 
 	ResultPayload = interface{}
+	FieldError    = serverErrors.FieldError
 )
 
 func NewErrorResult(code int, message string) Result {
@@ -27,7 +48,36 @@ func NewErrorResult(code int, message string) Result {
 		Ok: false,
 		Error: &ResultError{
 			Code:    code,
+			Kind:    serverErrors.StatusKind(code),
 			Message: message,
 		},
 	}
 }
+
+// NewErrorResultFrom builds error envelope from *Error.
+func NewErrorResultFrom(e *Error, requestID string) Result {
+	r := NewErrorResult(e.Code, e.Error())
+	r.Error.Kind = e.ErrorKind()
+	r.Error.RequestID = requestID
+	r.Error.Fields = e.Fields
+	r.Error.Meta = e.Meta
+	return r
+}
+
+// NewProblemResult converts error envelope into RFC 7807 problem details.
+func NewProblemResult(r Result, instance string) ProblemResult {
+	p := ProblemResult{
+		Type:      "about:blank",
+		Title:     http.StatusText(r.Error.Code),
+		Status:    r.Error.Code,
+		Instance:  instance,
+		Kind:      r.Error.Kind,
+		RequestID: r.Error.RequestID,
+		Fields:    r.Error.Fields,
+		Meta:      r.Error.Meta,
+	}
+	if r.Error.Message != p.Title {
+		p.Detail = r.Error.Message
+	}
+	return p
+}
@@ -0,0 +1,156 @@
+package secure
+
+import (
+	"strings"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+const (
+	FrameOptionsDeny       = "DENY"
+	FrameOptionsSameOrigin = "SAMEORIGIN"
+
+	// Off disables header with default value.
+	Off = "off"
+
+	// NonceSource is replaced with 'nonce-...' source of the current request in CSP directives.
+	NonceSource = "'nonce'"
+
+	DefaultReportURI = "/csp-report"
+)
+
+type Config struct {
+	Enable bool `yaml:"enable"`
+
+	HSTS               *HSTSConfig `yaml:"hsts"`
+	ContentTypeNosniff *bool       `yaml:"content-type-nosniff"`
+	// FrameOptions is X-Frame-Options value: DENY, SAMEORIGIN or off.
+	FrameOptions   string `yaml:"frame-options"`
+	ReferrerPolicy string `yaml:"referrer-policy"`
+	// CrossOriginOpenerPolicy is Cross-Origin-Opener-Policy value, off disables it.
+	CrossOriginOpenerPolicy string `yaml:"cross-origin-opener-policy"`
+	// PermissionsPolicy is Permissions-Policy value, empty disables it.
+	PermissionsPolicy string     `yaml:"permissions-policy"`
+	CSP               *CSPConfig `yaml:"csp"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.HSTS == nil:
+			c.HSTS = &HSTSConfig{}
+		case c.ContentTypeNosniff == nil:
+			v := true
+			c.ContentTypeNosniff = &v
+		case c.FrameOptions == "":
+			c.FrameOptions = FrameOptionsDeny
+		case c.ReferrerPolicy == "":
+			c.ReferrerPolicy = "strict-origin-when-cross-origin"
+		case c.CrossOriginOpenerPolicy == "":
+			c.CrossOriginOpenerPolicy = "same-origin"
+		case c.CSP == nil:
+			c.CSP = &CSPConfig{}
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	switch c.FrameOptions {
+	case FrameOptionsDeny, FrameOptionsSameOrigin, Off:
+	default:
+		return errors.Errorf(
+			"unexpected frame-options %q, expected one of: %q",
+			c.FrameOptions, []string{FrameOptionsDeny, FrameOptionsSameOrigin, Off},
+		)
+	}
+	return nil
+}
+
+//
+
+// HSTSConfig controls Strict-Transport-Security header,
+// it is sent only with requests made over https (including ones terminated by trusted proxy).
+type HSTSConfig struct {
+	Enable            *bool         `yaml:"enable"`
+	MaxAge            time.Duration `yaml:"max-age"`
+	IncludeSubdomains bool          `yaml:"include-subdomains"`
+	Preload           bool          `yaml:"preload"`
+}
+
+func (c *HSTSConfig) Default() {
+loop:
+	for {
+		switch {
+		case c.Enable == nil:
+			v := true
+			c.Enable = &v
+		case c.MaxAge <= 0:
+			c.MaxAge = 365 * 24 * time.Hour
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *HSTSConfig) Validate() error {
+	if c.Preload && (!c.IncludeSubdomains || c.MaxAge < 365*24*time.Hour) {
+		return errors.New("hsts preload requires include-subdomains and max-age of at least one year")
+	}
+	return nil
+}
+
+//
+
+type CSPConfig struct {
+	Enable bool `yaml:"enable"`
+	// ReportOnly sends policy in Content-Security-Policy-Report-Only header,
+	// so violations are reported but not enforced.
+	ReportOnly bool `yaml:"report-only"`
+	// Directives maps directive names to sources,
+	// 'nonce' source is replaced with per-request nonce.
+	Directives map[string][]string `yaml:"directives"`
+	// ReportURI is appended as report-uri directive, relative path mounts report collector,
+	// off disables reporting.
+	ReportURI string `yaml:"report-uri"`
+}
+
+func (c *CSPConfig) Default() {
+loop:
+	for {
+		switch {
+		case c.Directives == nil:
+			c.Directives = map[string][]string{
+				"default-src":     {"'self'"},
+				"script-src":      {"'self'", NonceSource},
+				"style-src":       {"'self'", NonceSource},
+				"img-src":         {"'self'", "data:"},
+				"object-src":      {"'none'"},
+				"base-uri":        {"'self'"},
+				"form-action":     {"'self'"},
+				"frame-ancestors": {"'none'"},
+			}
+		case c.ReportURI == "":
+			c.ReportURI = DefaultReportURI
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *CSPConfig) Validate() error {
+	for name, sources := range c.Directives {
+		if name == "" || strings.ContainsAny(name, " ;,") {
+			return errors.Errorf("invalid csp directive name %q", name)
+		}
+		for _, source := range sources {
+			if strings.ContainsAny(source, ";,") {
+				return errors.Errorf("invalid csp source %q in directive %q", source, name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package secure
+
+import (
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+)
+
+type Metrics struct {
+	Violations *collector.CounterVec
+}
+
+func NewMetrics(r *registry.Registry, subsystem string) *Metrics {
+	f := registry.NewFactory(r, subsystem)
+	return &Metrics{
+		Violations: f.CounterVec(
+			"csp_violations_total",
+			"How many CSP violations were reported, partitioned by directive and disposition.",
+			"directive", "disposition",
+		),
+	}
+}
@@ -0,0 +1,299 @@
+package secure
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/crypto"
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+const (
+	NonceContextKey = "csp-nonce"
+	NonceSize       = 16
+
+	HeaderCrossOriginOpenerPolicy = "Cross-Origin-Opener-Policy"
+	HeaderPermissionsPolicy       = "Permissions-Policy"
+
+	MIMEApplicationCSPReport   = "application/csp-report"
+	MIMEApplicationReportsJSON = "application/reports+json"
+
+	reportTypeCSP = "csp-violation"
+
+	DispositionEnforce = "enforce"
+	DispositionReport  = "report"
+
+	// DirectiveUnknown is reported for directives missing in KnownDirectives.
+	DirectiveUnknown = "unknown"
+)
+
+// KnownDirectives are CSP directive names which could be violated,
+// reports are sent by anyone, so only these are used as metric labels.
+var KnownDirectives = map[string]struct{}{
+	"base-uri":                  {},
+	"child-src":                 {},
+	"connect-src":               {},
+	"default-src":               {},
+	"fenced-frame-src":          {},
+	"font-src":                  {},
+	"form-action":               {},
+	"frame-ancestors":           {},
+	"frame-src":                 {},
+	"img-src":                   {},
+	"manifest-src":              {},
+	"media-src":                 {},
+	"navigate-to":               {},
+	"object-src":                {},
+	"prefetch-src":              {},
+	"require-trusted-types-for": {},
+	"sandbox":                   {},
+	"script-src":                {},
+	"script-src-attr":           {},
+	"script-src-elem":           {},
+	"style-src":                 {},
+	"style-src-attr":            {},
+	"style-src-elem":            {},
+	"trusted-types":             {},
+	"worker-src":                {},
+}
+
+type (
+	Secure struct {
+		config  Config
+		rand    crypto.Rand
+		headers map[string]string
+		hsts    string
+		// policy contains NonceSource placeholders when nonce is used.
+		policy       string
+		policyHeader string
+		nonce        bool
+	}
+
+	// Report is a CSP violation report,
+	// both report-uri (application/csp-report) and Reporting API formats are decoded into it.
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		Referrer           string `json:"referrer"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		BlockedURI         string `json:"blocked-uri"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		StatusCode         int    `json:"status-code"`
+	}
+
+	reportingAPIReport struct {
+		Type string `json:"type"`
+		Body struct {
+			DocumentURL        string `json:"documentURL"`
+			Referrer           string `json:"referrer"`
+			EffectiveDirective string `json:"effectiveDirective"`
+			BlockedURL         string `json:"blockedURL"`
+			Disposition        string `json:"disposition"`
+			SourceFile         string `json:"sourceFile"`
+			LineNumber         int    `json:"lineNumber"`
+			StatusCode         int    `json:"statusCode"`
+		} `json:"body"`
+	}
+)
+
+// Apply sets security headers for the current request,
+// CSP nonce is generated and stored in context when policy uses it.
+func (s *Secure) Apply(c echo.Context) error {
+	h := c.Response().Header()
+	for k, v := range s.headers {
+		h.Set(k, v)
+	}
+	if s.hsts != "" && c.Scheme() == "https" {
+		h.Set(echo.HeaderStrictTransportSecurity, s.hsts)
+	}
+
+	if s.policy == "" {
+		return nil
+	}
+	policy := s.policy
+	if s.nonce {
+		nonce, err := s.newNonce()
+		if err != nil {
+			return err
+		}
+		c.Set(NonceContextKey, nonce)
+		policy = strings.ReplaceAll(policy, NonceSource, "'nonce-"+nonce+"'")
+	}
+	h.Set(s.policyHeader, policy)
+
+	return nil
+}
+
+func (s *Secure) newNonce() (string, error) {
+	buf := make([]byte, NonceSize)
+	_, err := io.ReadFull(s.rand, buf)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate csp nonce")
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// ReportPath returns path to mount report collector on,
+// it is empty when reporting is disabled or report URI is absolute.
+func (s *Secure) ReportPath() string {
+	csp := s.config.CSP
+	if !csp.Enable || !strings.HasPrefix(csp.ReportURI, "/") {
+		return ""
+	}
+	return csp.ReportURI
+}
+
+// Nonce returns CSP nonce of the current request (empty if policy does not use it),
+// it should be set as nonce attribute of inline scripts and styles.
+func Nonce(c echo.Context) string {
+	nonce, _ := c.Get(NonceContextKey).(string)
+	return nonce
+}
+
+//
+
+// ParseReports decodes violation reports, reports of other types are skipped.
+func ParseReports(contentType string, body []byte) ([]Report, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse content type %q", contentType)
+	}
+
+	switch mediaType {
+	case MIMEApplicationCSPReport, echo.MIMEApplicationJSON:
+		envelope := struct {
+			Report Report `json:"csp-report"`
+		}{}
+		err = json.Unmarshal(body, &envelope)
+		if err != nil {
+			return nil, err
+		}
+		return []Report{envelope.Report}, nil
+	case MIMEApplicationReportsJSON:
+		reports := []reportingAPIReport{}
+		err = json.Unmarshal(body, &reports)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]Report, 0, len(reports))
+		for _, r := range reports {
+			if r.Type != reportTypeCSP {
+				continue
+			}
+			result = append(result, Report{
+				DocumentURI:        r.Body.DocumentURL,
+				Referrer:           r.Body.Referrer,
+				EffectiveDirective: r.Body.EffectiveDirective,
+				BlockedURI:         r.Body.BlockedURL,
+				Disposition:        r.Body.Disposition,
+				SourceFile:         r.Body.SourceFile,
+				LineNumber:         r.Body.LineNumber,
+				StatusCode:         r.Body.StatusCode,
+			})
+		}
+		return result, nil
+	default:
+		return nil, errors.Errorf("unsupported report content type %q", mediaType)
+	}
+}
+
+// Directive returns directive name suitable for metric label.
+func (r Report) Directive() string {
+	directive := r.EffectiveDirective
+	if directive == "" {
+		directive = r.ViolatedDirective
+	}
+	directive = strings.ToLower(strings.SplitN(strings.TrimSpace(directive), " ", 2)[0])
+	if _, ok := KnownDirectives[directive]; !ok {
+		return DirectiveUnknown
+	}
+	return directive
+}
+
+// DispositionName returns disposition suitable for metric label,
+// enforce is assumed when disposition is missing or unexpected.
+func (r Report) DispositionName() string {
+	if strings.EqualFold(strings.TrimSpace(r.Disposition), DispositionReport) {
+		return DispositionReport
+	}
+	return DispositionEnforce
+}
+
+//
+
+func buildPolicy(c CSPConfig) (string, bool) {
+	names := make([]string, 0, len(c.Directives))
+	for name := range c.Directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		directives = make([]string, 0, len(names)+1)
+		nonce      bool
+	)
+	for _, name := range names {
+		sources := c.Directives[name]
+		for _, source := range sources {
+			nonce = nonce || source == NonceSource
+		}
+		directives = append(directives, strings.TrimSpace(name+" "+strings.Join(sources, " ")))
+	}
+	if c.ReportURI != Off {
+		directives = append(directives, "report-uri "+c.ReportURI)
+	}
+	return strings.Join(directives, "; "), nonce
+}
+
+func New(c Config, rand crypto.Rand) *Secure {
+	s := &Secure{
+		config:  c,
+		rand:    rand,
+		headers: map[string]string{},
+	}
+
+	if *c.ContentTypeNosniff {
+		s.headers[echo.HeaderXContentTypeOptions] = "nosniff"
+	}
+	if c.FrameOptions != Off {
+		s.headers[echo.HeaderXFrameOptions] = c.FrameOptions
+	}
+	if c.ReferrerPolicy != Off {
+		s.headers[echo.HeaderReferrerPolicy] = c.ReferrerPolicy
+	}
+	if c.CrossOriginOpenerPolicy != Off {
+		s.headers[HeaderCrossOriginOpenerPolicy] = c.CrossOriginOpenerPolicy
+	}
+	if c.PermissionsPolicy != "" {
+		s.headers[HeaderPermissionsPolicy] = c.PermissionsPolicy
+	}
+
+	if *c.HSTS.Enable {
+		s.hsts = "max-age=" + strconv.FormatInt(int64(c.HSTS.MaxAge.Seconds()), 10)
+		if c.HSTS.IncludeSubdomains {
+			s.hsts += "; includeSubDomains"
+		}
+		if c.HSTS.Preload {
+			s.hsts += "; preload"
+		}
+	}
+
+	if c.CSP.Enable {
+		s.policy, s.nonce = buildPolicy(*c.CSP)
+		s.policyHeader = echo.HeaderContentSecurityPolicy
+		if c.CSP.ReportOnly {
+			s.policyHeader = echo.HeaderContentSecurityPolicyReportOnly
+		}
+	}
+
+	return s
+}
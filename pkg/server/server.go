@@ -1,27 +1,64 @@
 package server
 
 import (
+	"context"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/gommon/bytes"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
 	echo "github.com/labstack/echo/v4"
 	echomw "github.com/labstack/echo/v4/middleware"
 
+	"git.backbone/corpix/goboilerplate/pkg/crypto"
+	"git.backbone/corpix/goboilerplate/pkg/errors"
 	"git.backbone/corpix/goboilerplate/pkg/log"
+	"git.backbone/corpix/goboilerplate/pkg/server/csrf"
+	"git.backbone/corpix/goboilerplate/pkg/server/idempotency"
 	"git.backbone/corpix/goboilerplate/pkg/server/middleware"
+	"git.backbone/corpix/goboilerplate/pkg/server/ratelimit"
+	"git.backbone/corpix/goboilerplate/pkg/server/secure"
 	"git.backbone/corpix/goboilerplate/pkg/server/session"
+	"git.backbone/corpix/goboilerplate/pkg/server/static"
+	"git.backbone/corpix/goboilerplate/pkg/server/template"
+	"git.backbone/corpix/goboilerplate/pkg/server/websocket"
 	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
 	telemetry "git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/trace"
 )
 
 type (
 	HTTPServer = http.Server
 	HTTPOption = func(*HTTPServer)
 
+	Option = func(*Server)
+
 	MiddlewareFunc = echo.MiddlewareFunc
 	HandlerFunc    = echo.HandlerFunc
 
-	Server struct{ *echo.Echo }
+	Server struct {
+		*echo.Echo
+		// TLS is set when TLSConfig is enabled,
+		// listener should be wrapped with TLS.Config().
+		TLS *TLS
+		// Assets are set when static.Config is enabled or assets are mounted.
+		Assets *static.Assets
+		// WebSocket is set when websocket.Config is enabled,
+		// its connections are closed on Shutdown.
+		WebSocket *websocket.Upgrader
+		config    Config
+		rateLimit map[string]MiddlewareFunc
+		running   *sync.WaitGroup
+
+		// served is a server passed to StartServer,
+		// it is served directly, so echo does not shut it down.
+		served   *HTTPServer
+		servedMu sync.Mutex
+	}
 
 	Headers  = http.Header
 	Context  = echo.Context
@@ -45,7 +82,11 @@ type (
 		Any(path string, h HandlerFunc, m ...MiddlewareFunc) []*Route
 	}
 
-	router struct{ *echo.Group }
+	router struct {
+		*echo.Group
+		server *Server
+		prefix string
+	}
 )
 
 const (
@@ -134,7 +175,9 @@ const (
 	HeaderUpgrade                         = echo.HeaderUpgrade
 	HeaderVary                            = echo.HeaderVary
 	HeaderWWWAuthenticate                 = echo.HeaderWWWAuthenticate
+	HeaderForwarded                       = "Forwarded"
 	HeaderXForwardedFor                   = echo.HeaderXForwardedFor
+	HeaderXForwardedHost                  = "X-Forwarded-Host"
 	HeaderXForwardedProto                 = echo.HeaderXForwardedProto
 	HeaderXForwardedProtocol              = echo.HeaderXForwardedProtocol
 	HeaderXForwardedSsl                   = echo.HeaderXForwardedSsl
@@ -168,11 +211,28 @@ const (
 //
 
 func (r *router) Router(prefix string, m ...MiddlewareFunc) Router {
-	return &router{Group: r.Group.Group(prefix, m...)}
+	full := r.prefix + prefix
+	return &router{
+		Group:  r.Group.Group(prefix, r.server.groupMiddleware(full, m)...),
+		server: r.server,
+		prefix: full,
+	}
 }
 
 func (s *Server) Router(prefix string, m ...MiddlewareFunc) Router {
-	return &router{Group: s.Echo.Group(prefix, m...)}
+	return &router{
+		Group:  s.Echo.Group(prefix, s.groupMiddleware(prefix, m)...),
+		server: s,
+		prefix: prefix,
+	}
+}
+
+// groupMiddleware prepends middleware configured for the route group prefix.
+func (s *Server) groupMiddleware(prefix string, m []MiddlewareFunc) []MiddlewareFunc {
+	if mw, ok := s.rateLimit[prefix]; ok {
+		return append([]MiddlewareFunc{mw}, m...)
+	}
+	return m
 }
 
 //
@@ -197,7 +257,15 @@ func ComposeMiddleware(mw ...MiddlewareFunc) MiddlewareFunc {
 func HTTPTimeoutOption(c TimeoutConfig) HTTPOption {
 	return func(s *HTTPServer) {
 		s.ReadTimeout = c.Read
+		s.ReadHeaderTimeout = c.ReadHeader
 		s.WriteTimeout = c.Write
+		s.IdleTimeout = c.Idle
+	}
+}
+
+func HTTPLimitOption(c LimitConfig) HTTPOption {
+	return func(s *HTTPServer) {
+		s.MaxHeaderBytes = c.MaxHeaderBytes
 	}
 }
 
@@ -209,30 +277,266 @@ func NewHTTPServer(addr string, options ...HTTPOption) *HTTPServer {
 	return s
 }
 
-func New(c Config, subsystem string, name string, l log.Logger, r *telemetry.Registry) (*Server, error) {
+// NewHTTPServer creates HTTPServer configured with server timeouts, limits and TLS.
+func (s *Server) NewHTTPServer(addr string, options ...HTTPOption) *HTTPServer {
+	defaults := []HTTPOption{
+		HTTPTimeoutOption(*s.config.Timeout),
+		HTTPLimitOption(*s.config.Limit),
+	}
+	if s.TLS != nil {
+		defaults = append(defaults, HTTPTLSOption(s.TLS))
+	}
+	return NewHTTPServer(addr, append(defaults, options...)...)
+}
+
+// StartServer serves HTTPServer, configuring HTTP/2 (both TLS and h2c).
+func (s *Server) StartServer(hs *HTTPServer) error {
+	s.servedMu.Lock()
+	s.served = hs
+	s.servedMu.Unlock()
+
+	h2s := &http2.Server{
+		MaxConcurrentStreams: s.config.HTTP2.MaxConcurrentStreams,
+		MaxReadFrameSize:     s.config.HTTP2.MaxReadFrameSize,
+		IdleTimeout:          hs.IdleTimeout,
+	}
+
+	if hs.TLSConfig != nil {
+		err := http2.ConfigureServer(hs, h2s)
+		if err != nil {
+			return err
+		}
+		return s.Echo.StartServer(hs)
+	}
+	if !s.config.HTTP2.H2C {
+		return s.Echo.StartServer(hs)
+	}
+
+	// NOTE: echo.StartServer replaces handler, so h2c serving is set up here
+	var err error
+	if s.Listener == nil {
+		s.Listener, err = net.Listen("tcp", hs.Addr)
+		if err != nil {
+			return err
+		}
+	}
+	hs.ErrorLog = s.StdLogger
+	hs.Handler = h2c.NewHandler(s.Echo, h2s)
+
+	return hs.Serve(s.Listener)
+}
+
+// MountAssets serves assets under their prefix,
+// with SPA enabled index is also served for routes unmatched by application.
+func (s *Server) MountAssets(a *static.Assets) {
+	s.Assets = a
+
+	c := a.Config()
+	prefix := strings.TrimSuffix(c.Prefix, "/")
+	s.GET(prefix+"/*", a.Handler())
+	s.HEAD(prefix+"/*", a.Handler())
+	if c.SPA && prefix != "" {
+		s.GET("/*", a.SPAHandler())
+	}
+}
+
+// Shutdown closes websocket connections (hijacked connections are not tracked
+// by http.Server) and gracefully shuts down the server.
+// HTTP server is shut down even if websocket connections were not closed in time.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var err error
+	if s.WebSocket != nil {
+		err = s.WebSocket.Shutdown(ctx)
+		if err != nil {
+			err = errors.Wrap(err, "failed to close websocket connections")
+		}
+	}
+	return errors.CombineErrors(err, s.httpServer().Shutdown(ctx))
+}
+
+// Close immediately closes server listeners and connections.
+func (s *Server) Close() error {
+	return s.httpServer().Close()
+}
+
+// httpServer returns server started with StartServer,
+// falling back to echo server when server was not started with it.
+func (s *Server) httpServer() interface {
+	Shutdown(context.Context) error
+	Close() error
+} {
+	s.servedMu.Lock()
+	defer s.servedMu.Unlock()
+	if s.served != nil {
+		return s.served
+	}
+	return s.Echo
+}
+
+// WithWaitGroup adds open websocket connections to wg (usually application running group),
+// so process waits for connections to close before exit.
+func WithWaitGroup(wg *sync.WaitGroup) Option {
+	return func(s *Server) {
+		s.running = wg
+	}
+}
+
+// New creates server, nil tracer disables request tracing.
+func New(c Config, subsystem string, name string, l log.Logger, r *telemetry.Registry, t *trace.Tracer, options ...Option) (*Server, error) {
 	e := echo.New()
 	e.HideBanner = true
 	e.Logger = &middleware.Logger{Logger: l}
 	e.HTTPErrorHandler = DefaultHTTPErrorHandler
+	e.Validator = NewValidator()
+	e.Binder = NewBinder(e.Validator)
 
-	ipExtractorOptions := make([]TrustOption, len(c.IPExtractor.TrustCIDR))
-	for n, cidr := range c.IPExtractor.TrustCIDR {
-		_, ipnet, err := net.ParseCIDR(cidr)
+	trustOptions, err := NewTrustOptions(*c.IPExtractor)
+	if err != nil {
+		return nil, err
+	}
+	ipExtractor, err := NewIPExtractor(*c.IPExtractor)
+	if err != nil {
+		return nil, err
+	}
+
+	e.IPExtractor = echo.IPExtractor(ipExtractor)
+	e.Pre(RewriteForwarded(c.IPExtractor.Strategy, trustOptions...))
+
+	// NOTE: applications which embed templates or assets into the binary
+	// should construct renderer with template.New and assign it to Server.Renderer,
+	// assets should be constructed with static.New and mounted with Server.MountAssets
+	var assets *static.Assets
+	if c.Static.Enable {
+		assets, err = static.New(*c.Static, nil)
 		if err != nil {
 			return nil, err
 		}
-
-		ipExtractorOptions[n] = TrustIPRange(ipnet)
 	}
-
-	e.IPExtractor = echo.IPExtractor(ExtractIPFromRealIPHeader(ipExtractorOptions...))
+	if c.Template.Enable {
+		templateOptions := []template.Option{}
+		if assets != nil {
+			templateOptions = append(templateOptions, template.WithFuncs(assets.TemplateFuncs()))
+		}
+		renderer, err := template.New(*c.Template, nil, templateOptions...)
+		if err != nil {
+			return nil, err
+		}
+		e.Renderer = renderer
+	}
 
 	//
 
 	e.Use(echomw.RequestID())
+	if t != nil {
+		e.Use(middleware.NewTrace(t))
+	}
 	e.Use(middleware.NewLogger(l, ""))
-	e.Use(middleware.NewTelemetry(r, collector.NamePart(subsystem, name)))
+	e.Use(middleware.NewTelemetry(r, collector.NamePart(subsystem, name), *c.Telemetry))
 	e.Use(middleware.NewRecover(nil, l))
 
-	return &Server{Echo: e}, nil
+	if c.Timeout.Handler > 0 {
+		e.Use(middleware.NewTimeout(c.Timeout.Handler))
+	}
+
+	bodyLimit, err := bytes.Parse(c.Limit.Body)
+	if err != nil {
+		return nil, err
+	}
+	routesBodyLimit := make(map[string]int64, len(c.Limit.Routes))
+	for path, limit := range c.Limit.Routes {
+		routesBodyLimit[path], err = bytes.Parse(limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	e.Use(middleware.NewBodyLimit(bodyLimit, routesBodyLimit))
+
+	if c.Secure.Enable {
+		s := secure.New(*c.Secure, crypto.DefaultRand)
+		e.Use(middleware.NewSecure(s))
+
+		if path := s.ReportPath(); path != "" {
+			metrics := secure.NewMetrics(r, collector.NamePart(subsystem, name))
+			e.POST(path, middleware.NewCSPReport(metrics))
+		}
+	}
+
+	// NOTE: origin verification is independent from tokens, so it is applied to all routes,
+	// per route policies are matched by route path
+	if c.CSRFOrigin.Enable {
+		e.Use(middleware.NewCSRFOrigin(csrf.NewOrigin(*c.CSRFOrigin), r, collector.NamePart(subsystem, name)))
+	}
+
+	if c.Compress.Enable {
+		minSize, err := bytes.Parse(c.Compress.MinSize)
+		if err != nil {
+			return nil, err
+		}
+		encoders := make([]middleware.CompressEncoder, len(c.Compress.Encodings))
+		for n, encoding := range c.Compress.Encodings {
+			encoders[n] = middleware.CompressEncoders[encoding]
+		}
+		e.Use(middleware.NewCompress(encoders, int(minSize), c.Compress.ContentTypes))
+	}
+
+	//
+
+	srv := &Server{
+		Echo:      e,
+		config:    c,
+		rateLimit: map[string]MiddlewareFunc{},
+	}
+	for _, option := range options {
+		option(srv)
+	}
+
+	if assets != nil {
+		srv.MountAssets(assets)
+	}
+
+	if c.TLS.Enable {
+		srv.TLS, err = NewTLS(*c.TLS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.WebSocket.Enable {
+		wsOptions := []websocket.Option{
+			websocket.WithMetrics(websocket.NewMetrics(r, collector.NamePart(subsystem, name))),
+		}
+		if srv.running != nil {
+			wsOptions = append(wsOptions, websocket.WithWaitGroup(srv.running))
+		}
+		srv.WebSocket, err = websocket.New(*c.WebSocket, wsOptions...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.RateLimit.Enable {
+		var (
+			store   = ratelimit.NewMemoryStore()
+			metrics = ratelimit.NewMetrics(r, collector.NamePart(subsystem, name))
+		)
+
+		e.Use(middleware.NewRateLimit(ratelimit.New("default", *c.RateLimit.Policy, store), metrics))
+		for prefix, policy := range c.RateLimit.Groups {
+			srv.rateLimit[prefix] = middleware.NewRateLimit(ratelimit.New(prefix, *policy, store), metrics)
+		}
+	}
+
+	// NOTE: idempotency middleware goes after rate limiter, so replays are rate limited too,
+	// applications with shared stores should construct it with their own idempotency.Store.
+	// It runs before application session middleware, so session scope falls back to ip here.
+	if c.Idempotency.Enable {
+		i, err := idempotency.New(*c.Idempotency, idempotency.NewMemoryStore(c.Idempotency.MaxEntries))
+		if err != nil {
+			return nil, err
+		}
+		metrics := idempotency.NewMetrics(r, collector.NamePart(subsystem, name))
+		e.Use(middleware.NewIdempotency(i, metrics))
+	}
+
+	return srv, nil
 }
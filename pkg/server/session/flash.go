@@ -0,0 +1,50 @@
+package session
+
+import (
+	"encoding/json"
+)
+
+// FlashKey is a reserved payload key which holds flash messages.
+const FlashKey PayloadKey = 0xff01
+
+// AddFlash appends message which will be available until next Flashes() call.
+func (s *Session) AddFlash(message string) error {
+	messages, err := s.flashes()
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(append(messages, message))
+	if err != nil {
+		return err
+	}
+	s.Set(FlashKey, buf)
+
+	return nil
+}
+
+// Flashes returns flash messages and removes them from the session.
+func (s *Session) Flashes() ([]string, error) {
+	messages, err := s.flashes()
+	if err != nil {
+		return nil, err
+	}
+	s.Del(FlashKey)
+
+	return messages, nil
+}
+
+func (s *Session) flashes() ([]string, error) {
+	buf, ok := s.Get(FlashKey)
+	if !ok {
+		return nil, nil
+	}
+
+	messages := []string{}
+	err := json.Unmarshal(buf, &messages)
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
@@ -1,12 +1,15 @@
 package session
 
 import (
+	"encoding/hex"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"git.backbone/corpix/goboilerplate/pkg/crypto"
 	"git.backbone/corpix/goboilerplate/pkg/crypto/container"
+	"git.backbone/corpix/goboilerplate/pkg/errors"
 	"git.backbone/corpix/goboilerplate/pkg/meta"
 )
 
@@ -19,6 +22,11 @@ const (
 	SameSiteLax     = "lax"
 	SameSiteStrict  = "strict"
 	SameSiteNone    = "none"
+
+	// IDKey is a reserved payload key which holds random session identifier.
+	// Identifier is stable across session refreshes (unlike header nonce).
+	IDKey  PayloadKey = 0xff00
+	IDSize            = 16
 )
 
 var (
@@ -89,6 +97,33 @@ func (s *Session) Refresh() {
 	s.container.Refresh(t, t.Add(s.config.MaxAge))
 }
 
+// ID returns session identifier, generating a new one if session has no identifier yet.
+// Generating identifier modifies the session, so it should be saved afterwards.
+func (s *Session) ID() (string, error) {
+	id, ok := s.container.Get(IDKey)
+	if ok && len(id) == IDSize {
+		return hex.EncodeToString(id), nil
+	}
+
+	id = make(PayloadValue, IDSize)
+	_, err := io.ReadFull(s.rand, id)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read session id bytes from entropy source")
+	}
+	s.Set(IDKey, id)
+
+	return hex.EncodeToString(id), nil
+}
+
+// LookupID returns session identifier if session has one, session is not modified.
+func (s *Session) LookupID() (string, bool) {
+	id, ok := s.container.Get(IDKey)
+	if !ok || len(id) != IDSize {
+		return "", false
+	}
+	return hex.EncodeToString(id), true
+}
+
 //
 
 func (s *Session) Get(key PayloadKey) (PayloadValue, bool) {
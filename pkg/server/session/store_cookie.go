@@ -59,7 +59,7 @@ func (s *CookieStore) Drop() error {
 func (s *CookieStore) setCookie(value []byte, maxAge time.Duration, expires time.Time) {
 	domain := s.config.Domain
 	if domain == "" {
-		domain = s.context.Request().URL.Host
+		domain = s.context.Request().Host
 	}
 	// net/http: invalid Cookie.Domain "xxx.localhost:4180"; dropping domain attribute
 	domain = strings.Split(domain, ":")[0]
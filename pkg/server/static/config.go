@@ -0,0 +1,57 @@
+package static
+
+import (
+	"strings"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+type Config struct {
+	Enable bool `yaml:"enable"`
+	// Dir is an assets root directory, used when no file system was provided.
+	Dir string `yaml:"dir"`
+	// Prefix is an URL path assets are served under.
+	Prefix string `yaml:"prefix"`
+	// Fingerprint serves assets under content-hashed names (like app.1f2e3d4c5b6a7980.js)
+	// with immutable cache headers, use Assets.Path (asset template function) to resolve names.
+	Fingerprint *bool `yaml:"fingerprint"`
+	// MaxAge is a cache lifetime of assets requested by original names.
+	MaxAge time.Duration `yaml:"max-age"`
+	// Precompressed serves .zst and .gz siblings of assets to clients accepting them.
+	Precompressed *bool `yaml:"precompressed"`
+	// SPA serves Index for unmatched GET requests accepting HTML.
+	SPA   bool   `yaml:"spa"`
+	Index string `yaml:"index"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.Dir == "":
+			c.Dir = "static"
+		case c.Prefix == "":
+			c.Prefix = "/static"
+		case c.Fingerprint == nil:
+			v := true
+			c.Fingerprint = &v
+		case c.MaxAge <= 0:
+			c.MaxAge = time.Hour
+		case c.Precompressed == nil:
+			v := true
+			c.Precompressed = &v
+		case c.Index == "":
+			c.Index = "index.html"
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if !strings.HasPrefix(c.Prefix, "/") || (len(c.Prefix) > 1 && strings.HasSuffix(c.Prefix, "/")) {
+		return errors.Errorf("prefix %q should start with / and should not end with /", c.Prefix)
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package static
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/template"
+)
+
+const (
+	HeaderCacheControl = "Cache-Control"
+	HeaderETag         = "ETag"
+
+	CacheControlImmutable = "public, max-age=31536000, immutable"
+	CacheControlNoCache   = "no-cache"
+
+	// hashSize is a number of hash bytes used in fingerprinted names.
+	hashSize = 8
+)
+
+// precompressed are content codings of sibling files in server preference order.
+var precompressed = []struct {
+	encoding  string
+	extension string
+}{
+	{encoding: "zstd", extension: ".zst"},
+	{encoding: "gzip", extension: ".gz"},
+}
+
+type (
+	asset struct {
+		name        string
+		hash        string
+		contentType string
+		// variants maps content coding to precompressed file name.
+		variants map[string]string
+	}
+
+	// Assets serves files from file system, fingerprinted names
+	// are computed from file contents when assets are created.
+	Assets struct {
+		config        Config
+		fs            fs.FS
+		assets        map[string]*asset
+		fingerprinted map[string]*asset
+		manifest      map[string]string
+	}
+)
+
+// Path resolves asset name (relative to assets root) into URL path,
+// which is fingerprinted when Config.Fingerprint is enabled.
+func (a *Assets) Path(name string) (string, error) {
+	p, ok := a.manifest[strings.TrimPrefix(name, "/")]
+	if !ok {
+		return "", errors.Errorf("asset %q not found", name)
+	}
+	return p, nil
+}
+
+// Manifest maps asset names to URL paths.
+func (a *Assets) Manifest() map[string]string {
+	m := make(map[string]string, len(a.manifest))
+	for k, v := range a.manifest {
+		m[k] = v
+	}
+	return m
+}
+
+func (a *Assets) Config() Config { return a.config }
+
+// TemplateFuncs returns template functions:
+//   - asset resolves asset name into URL path (see Path)
+func (a *Assets) TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"asset": a.Path,
+	}
+}
+
+//
+
+// Handler serves assets, it should be mounted on Prefix + "/*".
+func (a *Assets) Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name := c.Param("*")
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+
+		if as, ok := a.fingerprinted[name]; ok {
+			return a.serve(c, as, CacheControlImmutable)
+		}
+		if as, ok := a.assets[name]; ok {
+			return a.serve(c, as, "public, max-age="+strconv.Itoa(int(a.config.MaxAge.Seconds())))
+		}
+		if a.config.SPA && wantsHTML(c.Request()) {
+			return a.serveIndex(c)
+		}
+		return echo.ErrNotFound
+	}
+}
+
+// SPAHandler serves Index to requests accepting HTML,
+// it should be mounted on "/*" so it handles routes unmatched by application.
+func (a *Assets) SPAHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !wantsHTML(c.Request()) {
+			return echo.ErrNotFound
+		}
+		return a.serveIndex(c)
+	}
+}
+
+func (a *Assets) serveIndex(c echo.Context) error {
+	as, ok := a.assets[a.config.Index]
+	if !ok {
+		return echo.ErrNotFound
+	}
+	// index references fingerprinted assets, so it should be revalidated
+	return a.serve(c, as, CacheControlNoCache)
+}
+
+func (a *Assets) serve(c echo.Context, as *asset, cacheControl string) error {
+	var (
+		req      = c.Request()
+		res      = c.Response()
+		h        = res.Header()
+		name     = as.name
+		encoding string
+	)
+
+	if *a.config.Precompressed && len(as.variants) > 0 {
+		h.Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+		accept := req.Header.Get(echo.HeaderAcceptEncoding)
+		for _, p := range precompressed {
+			variant, ok := as.variants[p.encoding]
+			if ok && acceptsEncoding(accept, p.encoding) {
+				name, encoding = variant, p.encoding
+				break
+			}
+		}
+	}
+
+	f, err := a.fs.Open(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open asset %q", name)
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		buf, err := io.ReadAll(f)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read asset %q", name)
+		}
+		content = bytes.NewReader(buf)
+	}
+
+	etag := as.hash
+	if encoding != "" {
+		etag += "-" + encoding
+		h.Set(echo.HeaderContentEncoding, encoding)
+	}
+	h.Set(echo.HeaderContentType, as.contentType)
+	h.Set(HeaderETag, `"`+etag+`"`)
+	h.Set(HeaderCacheControl, cacheControl)
+
+	// ServeContent handles ranges and If-None-Match (modification time is unknown for embedded files)
+	http.ServeContent(res, req, as.name, time.Time{}, content)
+	return nil
+}
+
+//
+
+func wantsHTML(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) &&
+		strings.Contains(req.Header.Get(echo.HeaderAccept), echo.MIMETextHTML)
+}
+
+// acceptsEncoding reports whether coding has non-zero quality in Accept-Encoding.
+func acceptsEncoding(accept string, encoding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "q") {
+				v, err := strconv.ParseFloat(kv[1], 64)
+				if err == nil {
+					q = v
+				}
+			}
+		}
+		switch name {
+		case encoding:
+			return q > 0
+		case "*":
+			wildcard = q > 0
+		}
+	}
+	return wildcard
+}
+
+func fingerprintName(name string, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+func (a *Assets) load() error {
+	files := map[string]struct{}{}
+	err := fs.WalkDir(a.fs, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files[name] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list assets")
+	}
+
+	variants := map[string]map[string]string{}
+	for name := range files {
+		for _, p := range precompressed {
+			original := strings.TrimSuffix(name, p.extension)
+			if _, ok := files[original]; ok && original != name {
+				if variants[original] == nil {
+					variants[original] = map[string]string{}
+				}
+				variants[original][p.encoding] = name
+				delete(files, name)
+			}
+		}
+	}
+
+	for name := range files {
+		buf, err := fs.ReadFile(a.fs, name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read asset %q", name)
+		}
+		sum := sha256.Sum256(buf)
+
+		contentType := mime.TypeByExtension(path.Ext(name))
+		if contentType == "" {
+			contentType = http.DetectContentType(buf)
+		}
+
+		as := &asset{
+			name:        name,
+			hash:        hex.EncodeToString(sum[:hashSize]),
+			contentType: contentType,
+			variants:    variants[name],
+		}
+		a.assets[name] = as
+
+		urlName := name
+		if *a.config.Fingerprint {
+			urlName = fingerprintName(name, as.hash)
+			a.fingerprinted[urlName] = as
+		}
+		a.manifest[name] = strings.TrimSuffix(a.config.Prefix, "/") + "/" + urlName
+	}
+
+	return nil
+}
+
+// New creates assets from the file system (like embed.FS, use fs.Sub to strip directory),
+// if file system is nil then Config.Dir is used.
+func New(c Config, fsys fs.FS) (*Assets, error) {
+	if fsys == nil {
+		fsys = os.DirFS(c.Dir)
+	}
+
+	a := &Assets{
+		config:        c,
+		fs:            fsys,
+		assets:        map[string]*asset{},
+		fingerprinted: map[string]*asset{},
+		manifest:      map[string]string{},
+	}
+	err := a.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
@@ -0,0 +1,46 @@
+package template
+
+import (
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+type Config struct {
+	Enable bool `yaml:"enable"`
+	// Dir is a templates root directory, used when no file system was provided.
+	Dir string `yaml:"dir"`
+	// Layout is a template name (relative to root) which wraps every page,
+	// page content is available inside layout as {{ template "content" . }}.
+	Layout string `yaml:"layout"`
+	// Partials is a glob pattern (relative to root) which matches templates
+	// available to every page.
+	Partials  string `yaml:"partials"`
+	Extension string `yaml:"extension"`
+	// Reload makes renderer parse templates on each render (useful for development).
+	Reload bool `yaml:"reload"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.Dir == "":
+			c.Dir = "templates"
+		case c.Partials == "":
+			c.Partials = "partials/*"
+		case c.Extension == "":
+			c.Extension = ".html"
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.Extension == "" {
+		return errors.New("extension should not be empty")
+	}
+	return nil
+}
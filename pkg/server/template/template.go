@@ -0,0 +1,368 @@
+package template
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/csrf"
+	"git.backbone/corpix/goboilerplate/pkg/server/secure"
+	"git.backbone/corpix/goboilerplate/pkg/server/session"
+)
+
+// ContentTemplate is a name of the template each page should define
+// to be rendered inside the layout.
+const ContentTemplate = "content"
+
+type (
+	Template = htmltemplate.Template
+	FuncMap  = htmltemplate.FuncMap
+	HTML     = htmltemplate.HTML
+
+	// ContextFuncs builds template functions bound to the request context,
+	// context is nil while templates are parsed.
+	ContextFuncs = func(echo.Context) FuncMap
+	Option       = func(*Renderer)
+
+	page struct {
+		name     string
+		template *Template
+	}
+)
+
+var _ echo.Renderer = new(Renderer)
+
+//
+
+// Renderer implements echo.Renderer over html/template.
+type Renderer struct {
+	config       Config
+	fs           fs.FS
+	funcs        FuncMap
+	contextFuncs []ContextFuncs
+	lock         *sync.RWMutex
+	pages        map[string]*page
+}
+
+func (r *Renderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	p, err := r.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	t, err := p.template.Clone()
+	if err != nil {
+		return errors.Wrapf(err, "failed to clone template %q", name)
+	}
+	t.Funcs(r.requestFuncs(c))
+
+	entry := p.name
+	if r.config.Layout != "" {
+		entry = r.config.Layout
+	}
+
+	err = t.ExecuteTemplate(w, entry, data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to render template %q", name)
+	}
+
+	return nil
+}
+
+// Load parses all templates from the file system.
+func (r *Renderer) Load() error {
+	pages, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	r.pages = pages
+	r.lock.Unlock()
+
+	return nil
+}
+
+func (r *Renderer) lookup(name string) (*page, error) {
+	if r.config.Reload {
+		err := r.Load()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	p, ok := r.pages[name]
+	if !ok {
+		return nil, errors.Errorf("template %q not found", name)
+	}
+
+	return p, nil
+}
+
+func (r *Renderer) load() (map[string]*page, error) {
+	base := htmltemplate.New("").
+		Funcs(r.funcs).
+		Funcs(r.requestFuncs(nil))
+
+	common := []string{}
+	if r.config.Layout != "" {
+		common = append(common, r.config.Layout)
+	}
+	partials, err := fs.Glob(r.fs, r.config.Partials)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to match partials %q", r.config.Partials)
+	}
+	common = append(common, partials...)
+
+	exclude := make(map[string]struct{}, len(common))
+	for _, name := range common {
+		exclude[name] = struct{}{}
+		err = r.parse(base, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//
+
+	pages := map[string]*page{}
+	err = fs.WalkDir(r.fs, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(name, r.config.Extension) {
+			return nil
+		}
+		if _, ok := exclude[name]; ok {
+			return nil
+		}
+
+		t, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		err = r.parse(t, name)
+		if err != nil {
+			return err
+		}
+
+		pages[strings.TrimSuffix(name, r.config.Extension)] = &page{
+			name:     name,
+			template: t,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pages, nil
+}
+
+func (r *Renderer) parse(t *Template, name string) error {
+	buf, err := fs.ReadFile(r.fs, name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read template %q", name)
+	}
+
+	_, err = t.New(name).Parse(string(buf))
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse template %q", name)
+	}
+
+	return nil
+}
+
+func (r *Renderer) requestFuncs(c echo.Context) FuncMap {
+	fm := Funcs(c)
+	for _, fn := range r.contextFuncs {
+		for k, v := range fn(c) {
+			fm[k] = v
+		}
+	}
+	return fm
+}
+
+//
+
+func errNoContext(name string) error {
+	return errors.Errorf("template function %q is not available outside of request context", name)
+}
+
+func contextCSRF(c echo.Context) (*csrf.CSRF, error) {
+	t, ok := c.Get(csrf.ContextKey).(*csrf.CSRF)
+	if !ok {
+		return nil, errors.Errorf(
+			"failed to load csrf from context key %q, csrf middleware is required",
+			csrf.ContextKey,
+		)
+	}
+	return t, nil
+}
+
+func contextSession(c echo.Context) *session.Session {
+	store, ok := session.GetStore(c)
+	if !ok {
+		return nil
+	}
+	return store.Session()
+}
+
+// Funcs returns template functions bound to the request context:
+//   - csrfToken returns CSRF token for the current request (any mode,
+//     in url mode token is bound to the URL of the rendered page)
+//   - csrfField returns hidden form input with CSRF token (session and double-submit modes),
+//     in url mode csrfURL should be used for form action instead
+//   - csrfURL signs URL (path with query, like form action) with CSRF token (url mode)
+//   - session returns current session (nil if session middleware is not used)
+//   - flashes returns flash messages removing them from session
+//   - request returns current request
+//   - realIP returns client IP address
+//   - requestID returns request identifier
+//   - cspNonce returns CSP nonce (empty if secure middleware does not use it)
+func Funcs(c echo.Context) FuncMap {
+	return FuncMap{
+		"csrfToken": func() (string, error) {
+			if c == nil {
+				return "", errNoContext("csrfToken")
+			}
+			t, err := contextCSRF(c)
+			if err != nil {
+				return "", err
+			}
+			return t.SignContext(c)
+		},
+		"csrfField": func() (HTML, error) {
+			if c == nil {
+				return "", errNoContext("csrfField")
+			}
+			t, err := contextCSRF(c)
+			if err != nil {
+				return "", err
+			}
+			switch t.Mode() {
+			case csrf.ModeURL:
+				return "", errors.Errorf(
+					"csrfField could not be used in %q csrf mode, token is validated from URL, use csrfURL to sign form action",
+					t.Mode(),
+				)
+			case csrf.ModeHeader:
+				return "", errors.Errorf(
+					"csrfField could not be used in %q csrf mode, token is validated from header, use csrfToken",
+					t.Mode(),
+				)
+			}
+			token, err := t.SignContext(c)
+			if err != nil {
+				return "", err
+			}
+			return HTML(fmt.Sprintf(
+				`<input type="hidden" name="%s" value="%s">`,
+				htmltemplate.HTMLEscapeString(t.ParameterName()),
+				htmltemplate.HTMLEscapeString(token),
+			)), nil
+		},
+		"csrfURL": func(u string) (string, error) {
+			if c == nil {
+				return "", errNoContext("csrfURL")
+			}
+			t, err := contextCSRF(c)
+			if err != nil {
+				return "", err
+			}
+			return t.SignURLString(c.RealIP(), u)
+		},
+		"session": func() (*session.Session, error) {
+			if c == nil {
+				return nil, errNoContext("session")
+			}
+			return contextSession(c), nil
+		},
+		"flashes": func() ([]string, error) {
+			if c == nil {
+				return nil, errNoContext("flashes")
+			}
+			s := contextSession(c)
+			if s == nil {
+				return nil, nil
+			}
+			return s.Flashes()
+		},
+		"request": func() (*http.Request, error) {
+			if c == nil {
+				return nil, errNoContext("request")
+			}
+			return c.Request(), nil
+		},
+		"realIP": func() (string, error) {
+			if c == nil {
+				return "", errNoContext("realIP")
+			}
+			return c.RealIP(), nil
+		},
+		"requestID": func() (string, error) {
+			if c == nil {
+				return "", errNoContext("requestID")
+			}
+			return c.Response().Header().Get(echo.HeaderXRequestID), nil
+		},
+		"cspNonce": func() (string, error) {
+			if c == nil {
+				return "", errNoContext("cspNonce")
+			}
+			return secure.Nonce(c), nil
+		},
+	}
+}
+
+//
+
+func WithFuncs(funcs FuncMap) Option {
+	return func(r *Renderer) {
+		for k, v := range funcs {
+			r.funcs[k] = v
+		}
+	}
+}
+
+func WithContextFuncs(fn ContextFuncs) Option {
+	return func(r *Renderer) {
+		r.contextFuncs = append(r.contextFuncs, fn)
+	}
+}
+
+// New creates renderer which loads templates from the file system,
+// if file system is nil then Config.Dir is used.
+func New(c Config, fsys fs.FS, options ...Option) (*Renderer, error) {
+	if fsys == nil {
+		fsys = os.DirFS(c.Dir)
+	}
+
+	r := &Renderer{
+		config: c,
+		fs:     fsys,
+		funcs:  FuncMap{},
+		lock:   &sync.RWMutex{},
+	}
+	for _, option := range options {
+		option(r)
+	}
+
+	err := r.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/log"
+)
+
+const (
+	TLSSubsystem = "tls"
+
+	TLSClientAuthNone             = "none"
+	TLSClientAuthRequest          = "request"
+	TLSClientAuthRequire          = "require"
+	TLSClientAuthVerifyIfGiven    = "verify-if-given"
+	TLSClientAuthRequireAndVerify = "require-and-verify"
+
+	TLSVersion10 = "1.0"
+	TLSVersion11 = "1.1"
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
+)
+
+var (
+	TLSClientAuth = map[string]tls.ClientAuthType{
+		TLSClientAuthNone:             tls.NoClientCert,
+		TLSClientAuthRequest:          tls.RequestClientCert,
+		TLSClientAuthRequire:          tls.RequireAnyClientCert,
+		TLSClientAuthVerifyIfGiven:    tls.VerifyClientCertIfGiven,
+		TLSClientAuthRequireAndVerify: tls.RequireAndVerifyClientCert,
+	}
+	TLSVersions = map[string]uint16{
+		TLSVersion10: tls.VersionTLS10,
+		TLSVersion11: tls.VersionTLS11,
+		TLSVersion12: tls.VersionTLS12,
+		TLSVersion13: tls.VersionTLS13,
+	}
+)
+
+// TLSCipherSuites resolves cipher suite names (as reported by tls.CipherSuiteName)
+// into identifiers, insecure suites are rejected.
+func TLSCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, len(names))
+	for n, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, errors.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids[n] = id
+	}
+	return ids, nil
+}
+
+//
+
+// TLS holds certificates loaded from TLSConfig files
+// and reloads them in place, so listeners need no restart.
+type TLS struct {
+	lock    sync.RWMutex
+	config  TLSConfig
+	tls     *tls.Config
+	modTime map[string]time.Time
+}
+
+// Config returns tls.Config for listeners,
+// it resolves current certificates on each handshake.
+// Protocols, cipher suites and min version are copied from current configuration,
+// so HTTP/2 is configured against the same policy which is negotiated.
+func (t *TLS) Config() *tls.Config {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return &tls.Config{
+		MinVersion:   t.tls.MinVersion,
+		CipherSuites: t.tls.CipherSuites,
+		NextProtos:   append([]string(nil), t.tls.NextProtos...),
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			t.lock.RLock()
+			defer t.lock.RUnlock()
+			return t.tls, nil
+		},
+	}
+}
+
+// Changed reports whether certificate files were modified since last load.
+func (t *TLS) Changed() bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for path, modTime := range t.modTime {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload loads certificates from files again,
+// non-nil c replaces current configuration.
+// On error previously loaded certificates stay in use.
+func (t *TLS) Reload(c *TLSConfig) error {
+	t.lock.RLock()
+	config := t.config
+	t.lock.RUnlock()
+
+	if c != nil {
+		config = *c
+	}
+
+	cfg, modTime, err := loadTLS(config)
+	if err != nil {
+		return err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.config = config
+	t.tls = cfg
+	t.modTime = modTime
+
+	return nil
+}
+
+// Watch polls certificate files and reloads them on change until done is closed.
+func (t *TLS) Watch(done <-chan struct{}, l log.Logger) {
+	t.lock.RLock()
+	interval := t.config.ReloadInterval
+	t.lock.RUnlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !t.Changed() {
+				continue
+			}
+			err := t.Reload(nil)
+			if err != nil {
+				l.Error().Err(err).Msg("failed to reload tls certificates")
+				continue
+			}
+			l.Info().Msg("reloaded tls certificates")
+		}
+	}
+}
+
+func loadTLS(c TLSConfig) (*tls.Config, map[string]time.Time, error) {
+	modTime := map[string]time.Time{}
+	paths := []string{c.Cert, c.Key}
+	if c.ClientCA != "" {
+		paths = append(paths, c.ClientCA)
+	}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		modTime[path] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load certificate %q with key %q", c.Cert, c.Key)
+	}
+	suites, err := TLSCipherSuites(c.CipherSuites)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   TLSVersions[c.MinVersion],
+		CipherSuites: suites,
+		ClientAuth:   TLSClientAuth[c.ClientAuth],
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if c.ClientCA != "" {
+		buf, err := ioutil.ReadFile(c.ClientCA)
+		if err != nil {
+			return nil, nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			return nil, nil, errors.Errorf("no certificates found in client ca %q", c.ClientCA)
+		}
+		cfg.ClientCAs = pool
+	}
+
+	return cfg, modTime, nil
+}
+
+func NewTLS(c TLSConfig) (*TLS, error) {
+	t := &TLS{config: c}
+	err := t.Reload(nil)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func HTTPTLSOption(t *TLS) HTTPOption {
+	return func(s *HTTPServer) {
+		s.TLSConfig = t.Config()
+	}
+}
+
+//
+
+// ClientCertificate returns client certificate presented over mTLS, if any.
+// Certificate is verified only with verify-if-given and require-and-verify client-auth,
+// use VerifiedClientCertificate to make decisions based on it.
+func ClientCertificate(c Context) *x509.Certificate {
+	state := c.Request().TLS
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
+
+// VerifiedClientCertificate returns client certificate presented over mTLS
+// if it was verified against client-ca.
+func VerifiedClientCertificate(c Context) *x509.Certificate {
+	state := c.Request().TLS
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
+// ClientSubject returns verified client certificate subject presented over mTLS, if any,
+// subject of certificate which was not verified is not returned.
+func ClientSubject(c Context) string {
+	cert := VerifiedClientCertificate(c)
+	if cert == nil {
+		return ""
+	}
+	return cert.Subject.String()
+}
@@ -0,0 +1,331 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/reflect"
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+)
+
+const (
+	ValidateTag               = "validate"
+	ValidateTagRulesDelimiter = ","
+	ValidateTagParamDelimiter = "="
+
+	ValidationRuleRequired = "required"
+	ValidationRuleMin      = "min"
+	ValidationRuleMax      = "max"
+	ValidationRuleLen      = "len"
+	ValidationRuleOneOf    = "oneof"
+	ValidationRuleEmail    = "email"
+	ValidationRuleURL      = "url"
+	ValidationRulePattern  = "pattern"
+)
+
+type (
+	// ValidationRule checks a single value against rule parameter (text after "=" in tag).
+	ValidationRule struct {
+		Check func(v reflect.Value, param string) (bool, error)
+		// Message is a default english message, %s is replaced with parameter.
+		Message string
+	}
+	ValidatorOption = func(*Validator)
+)
+
+// ValidationRules are rules available by default, rules are declared in struct tags:
+//
+//	Name string `json:"name" validate:"required,max=64"`
+//
+// Rules except required are skipped for zero values.
+// Pattern parameter could not contain commas.
+var ValidationRules = map[string]ValidationRule{
+	ValidationRuleRequired: {
+		Check:   func(v reflect.Value, _ string) (bool, error) { return !v.IsZero(), nil },
+		Message: "is required",
+	},
+	ValidationRuleMin: {
+		Check:   validateSize(func(size float64, limit float64) bool { return size >= limit }),
+		Message: "should be at least %s",
+	},
+	ValidationRuleMax: {
+		Check:   validateSize(func(size float64, limit float64) bool { return size <= limit }),
+		Message: "should be at most %s",
+	},
+	ValidationRuleLen: {
+		Check:   validateSize(func(size float64, limit float64) bool { return size == limit }),
+		Message: "should have length %s",
+	},
+	ValidationRuleOneOf: {
+		Check: func(v reflect.Value, param string) (bool, error) {
+			s := fmt.Sprint(v.Interface())
+			for _, variant := range strings.Fields(param) {
+				if s == variant {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		Message: "should be one of: %s",
+	},
+	ValidationRuleEmail: {
+		Check: validateString(func(s string, _ string) (bool, error) {
+			addr, err := mail.ParseAddress(s)
+			return err == nil && addr.Address == s, nil
+		}),
+		Message: "should be a valid email address",
+	},
+	ValidationRuleURL: {
+		Check: validateString(func(s string, _ string) (bool, error) {
+			u, err := url.ParseRequestURI(s)
+			return err == nil && u.Scheme != "" && u.Host != "", nil
+		}),
+		Message: "should be a valid absolute URL",
+	},
+	ValidationRulePattern: {
+		Check: validateString(func(s string, param string) (bool, error) {
+			re, err := compilePattern(param)
+			if err != nil {
+				return false, err
+			}
+			return re.MatchString(s), nil
+		}),
+		Message: "should match pattern %s",
+	},
+}
+
+var patterns sync.Map
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+func validateString(fn func(s string, param string) (bool, error)) func(reflect.Value, string) (bool, error) {
+	return func(v reflect.Value, param string) (bool, error) {
+		if v.Kind() != reflect.String {
+			return false, reflect.NewErrUnexpecterKind(v.Kind(), reflect.String)
+		}
+		return fn(v.String(), param)
+	}
+}
+
+// validateSize compares numbers by value and strings, slices and maps by length.
+func validateSize(cmp func(size float64, limit float64) bool) func(reflect.Value, string) (bool, error) {
+	return func(v reflect.Value, param string) (bool, error) {
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to parse size limit %q", param)
+		}
+
+		var size float64
+		switch v.Kind() {
+		case reflect.String:
+			size = float64(len([]rune(v.String())))
+		case reflect.Slice, reflect.Map, reflect.Array:
+			size = float64(v.Len())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			size = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			size = float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			size = v.Float()
+		default:
+			return false, reflect.NewErrUnexpecterKind(
+				v.Kind(),
+				reflect.String, reflect.Slice, reflect.Map, reflect.Int, reflect.Uint, reflect.Float64,
+			)
+		}
+		return cmp(size, limit), nil
+	}
+}
+
+//
+
+// Validator validates structs using rules from `validate` tag,
+// it implements echo.Validator.
+type Validator struct {
+	rules map[string]ValidationRule
+}
+
+// Validate returns *Error with field errors when v does not satisfy its rules.
+// Field names are taken from json tag when present.
+// Elements of slices, arrays and maps are validated, values of other kinds have no rules.
+func (vv *Validator) Validate(v interface{}) error {
+	var fields []FieldError
+	err := vv.validateValue(reflect.ValueOf(v), "", &fields)
+	if err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		return serverErrors.NewError(
+			http.StatusUnprocessableEntity, "validation failed",
+			nil, nil,
+		).
+			WithKind(serverErrors.KindValidation).
+			WithFields(fields...)
+	}
+	return nil
+}
+
+func (vv *Validator) validateStruct(rv reflect.Value, prefix string, fields *[]FieldError) error {
+	rt := rv.Type()
+	for n := 0; n < rt.NumField(); n++ {
+		ft := rt.Field(n)
+		if !reflect.StructFieldExported(ft) {
+			continue
+		}
+
+		name := validateFieldName(ft)
+		if name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		err := vv.validateField(rv.Field(n), ft.Tag.Get(ValidateTag), name, fields)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vv *Validator) validateField(fv reflect.Value, tag string, name string, fields *[]FieldError) error {
+	if tag == "-" {
+		return nil
+	}
+
+	if tag != "" {
+		for _, rule := range strings.Split(tag, ValidateTagRulesDelimiter) {
+			ok, param, err := vv.check(fv, rule)
+			if err != nil {
+				return errors.Wrapf(err, "failed to check rule %q for field %q", rule, name)
+			}
+			if !ok {
+				*fields = append(*fields, vv.fieldError(name, rule, param))
+				if strings.HasPrefix(rule, ValidationRuleRequired) {
+					return nil // nothing else to check
+				}
+			}
+		}
+	}
+
+	return vv.validateValue(fv, name, fields)
+}
+
+// validateValue validates struct fields and elements of slices, arrays and maps,
+// values of other kinds have no rules.
+func (vv *Validator) validateValue(rv reflect.Value, name string, fields *[]FieldError) error {
+	v := reflect.IndirectValue(rv)
+	if !v.IsValid() {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return vv.validateStruct(v, name, fields)
+	case reflect.Slice, reflect.Array:
+		for n := 0; n < v.Len(); n++ {
+			err := vv.validateValue(v.Index(n), fmt.Sprintf("%s[%d]", name, n), fields)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			err := vv.validateValue(iter.Value(), fmt.Sprintf("%s[%v]", name, iter.Key().Interface()), fields)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (vv *Validator) check(fv reflect.Value, rule string) (bool, string, error) {
+	var (
+		parts = strings.SplitN(rule, ValidateTagParamDelimiter, 2)
+		name  = strings.TrimSpace(parts[0])
+		param string
+	)
+	if len(parts) > 1 {
+		param = parts[1]
+	}
+
+	r, ok := vv.rules[name]
+	if !ok {
+		return false, param, errors.Errorf("unknown validation rule %q", name)
+	}
+
+	if name != ValidationRuleRequired {
+		if fv.IsZero() {
+			return true, param, nil
+		}
+		fv = reflect.IndirectValue(fv)
+	}
+
+	ok, err := r.Check(fv, param)
+	return ok, param, err
+}
+
+func (vv *Validator) fieldError(field string, rule string, param string) FieldError {
+	name := strings.SplitN(rule, ValidateTagParamDelimiter, 2)[0]
+	message := vv.rules[name].Message
+	if strings.Contains(message, "%s") {
+		message = fmt.Sprintf(message, param)
+	}
+
+	e := FieldError{
+		Field:   field,
+		Kind:    name,
+		Message: field + " " + message,
+	}
+	if param != "" {
+		e.Params = map[string]interface{}{"param": param}
+	}
+	return e
+}
+
+func validateFieldName(ft reflect.StructField) string {
+	name := strings.Split(ft.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return ft.Name
+	}
+	return name
+}
+
+//
+
+// WithValidationRule adds (or replaces) validation rule.
+func WithValidationRule(name string, rule ValidationRule) ValidatorOption {
+	return func(v *Validator) {
+		v.rules[name] = rule
+	}
+}
+
+func NewValidator(options ...ValidatorOption) *Validator {
+	v := &Validator{rules: make(map[string]ValidationRule, len(ValidationRules))}
+	for name, rule := range ValidationRules {
+		v.rules[name] = rule
+	}
+	for _, option := range options {
+		option(v)
+	}
+	return v
+}
@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"net/url"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+type Config struct {
+	Enable bool `yaml:"enable"`
+
+	// PingInterval defines how often server pings the client.
+	PingInterval time.Duration `yaml:"ping-interval"`
+	// PongTimeout closes connection if nothing was received from client for this duration.
+	PongTimeout  time.Duration `yaml:"pong-timeout"`
+	WriteTimeout time.Duration `yaml:"write-timeout"`
+	// CloseTimeout limits time waiting for client to respond to close frame.
+	CloseTimeout time.Duration `yaml:"close-timeout"`
+
+	MaxMessageSize int64 `yaml:"max-message-size"`
+	// SendQueue is a per-connection outgoing message queue length,
+	// slow clients which do not drain the queue within WriteTimeout are disconnected.
+	SendQueue int `yaml:"send-queue"`
+	// ReceiveQueue is a per-connection incoming message queue length,
+	// when it is full connection is not read until handler receives messages.
+	ReceiveQueue int `yaml:"receive-queue"`
+
+	// AllowOrigins lists origins (besides same origin) allowed to connect.
+	AllowOrigins []string `yaml:"allow-origins"`
+	// RequireOrigin rejects handshakes without Origin header (non-browser clients).
+	RequireOrigin bool `yaml:"require-origin"`
+	// CSRF requires valid CSRF token at handshake,
+	// token should be passed in query because browsers could not set headers for websockets.
+	CSRF bool `yaml:"csrf"`
+	// RequireSession rejects handshakes without session store in context.
+	RequireSession bool     `yaml:"require-session"`
+	Subprotocols   []string `yaml:"subprotocols"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.PingInterval <= 0:
+			c.PingInterval = 30 * time.Second
+		case c.PongTimeout <= 0:
+			c.PongTimeout = 2 * c.PingInterval
+		case c.WriteTimeout <= 0:
+			c.WriteTimeout = 10 * time.Second
+		case c.CloseTimeout <= 0:
+			c.CloseTimeout = 5 * time.Second
+		case c.MaxMessageSize <= 0:
+			c.MaxMessageSize = 1 << 20
+		case c.SendQueue <= 0:
+			c.SendQueue = 64
+		case c.ReceiveQueue <= 0:
+			c.ReceiveQueue = 16
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.PongTimeout <= c.PingInterval {
+		return errors.New("pong-timeout should be greater than ping-interval")
+	}
+	for _, origin := range c.AllowOrigins {
+		u, err := url.Parse(origin)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse allowed origin %q", origin)
+		}
+		if u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return errors.Errorf(
+				"allowed origin %q should be in form scheme://host[:port]",
+				origin,
+			)
+		}
+	}
+	return nil
+}
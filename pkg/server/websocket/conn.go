@@ -0,0 +1,398 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/session"
+)
+
+// Close codes are defined in RFC 6455 section 7.4.1.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseAbnormal        = 1006
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+	CloseTryAgainLater   = 1013
+)
+
+const (
+	TextMessage   MessageType = opText
+	BinaryMessage MessageType = opBinary
+
+	controlQueue = 4
+)
+
+var (
+	ErrClosed       = errors.New("websocket connection is closed")
+	ErrSlowConsumer = errors.New("websocket client does not read messages fast enough")
+)
+
+type (
+	MessageType byte
+	Message     struct {
+		Type MessageType
+		Data []byte
+	}
+
+	// Conn is a server side websocket connection.
+	// Incoming messages are read by background goroutine into bounded queue,
+	// outgoing messages are written by another goroutine from bounded queue,
+	// so Receive and Send are safe to use concurrently.
+	Conn struct {
+		config      Config
+		conn        net.Conn
+		r           *bufio.Reader
+		w           *bufio.Writer
+		request     *http.Request
+		session     *session.Session
+		subprotocol string
+		metrics     *Metrics
+
+		ctx    context.Context
+		cancel context.CancelFunc
+
+		in      chan Message
+		out     chan Message
+		control chan frame
+
+		closing       chan struct{}
+		closeOnce     sync.Once
+		closeDeadline time.Time
+		written       chan struct{}
+		done          chan struct{}
+		doneOnce      sync.Once
+
+		lock        sync.Mutex
+		closeCode   int
+		closeReason string
+	}
+)
+
+func newConn(c Config, conn net.Conn, rw *bufio.ReadWriter, req *http.Request, s *session.Session, subprotocol string, m *Metrics) *Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Conn{
+		config:      c,
+		conn:        conn,
+		r:           rw.Reader,
+		w:           rw.Writer,
+		request:     req,
+		session:     s,
+		subprotocol: subprotocol,
+		metrics:     m,
+
+		ctx:    ctx,
+		cancel: cancel,
+
+		in:      make(chan Message, c.ReceiveQueue),
+		out:     make(chan Message, c.SendQueue),
+		control: make(chan frame, controlQueue),
+
+		closing: make(chan struct{}),
+		written: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (c *Conn) start() {
+	go c.readLoop()
+	go c.writeLoop()
+}
+
+// Context is canceled when connection is closed,
+// it does not inherit request context because handler timeouts should not
+// limit connection lifetime, use Request to access request values.
+func (c *Conn) Context() context.Context { return c.ctx }
+func (c *Conn) Request() *http.Request   { return c.request }
+func (c *Conn) RemoteAddr() net.Addr     { return c.conn.RemoteAddr() }
+func (c *Conn) Subprotocol() string      { return c.subprotocol }
+
+// Session is a session loaded at handshake (nil without session middleware),
+// changes are not saved because response headers are already sent.
+func (c *Conn) Session() *session.Session { return c.session }
+
+// Done is closed when underlying connection is closed.
+func (c *Conn) Done() <-chan struct{} { return c.done }
+
+// CloseStatus returns close code and reason of the first close frame
+// (sent or received), CloseAbnormal means connection was dropped.
+func (c *Conn) CloseStatus() (int, string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.closeCode, c.closeReason
+}
+
+// Receive waits for the next message, it returns ErrClosed after connection is closed.
+// Messages are not read from the network while receive queue is full.
+func (c *Conn) Receive() (Message, error) {
+	m, ok := <-c.in
+	if !ok {
+		return Message{}, ErrClosed
+	}
+	return m, nil
+}
+
+// Send enqueues message, when queue is full it waits for WriteTimeout
+// and closes connection with ErrSlowConsumer if client still does not keep up.
+func (c *Conn) Send(m Message) error {
+	select {
+	case <-c.closing:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case c.out <- m:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(c.config.WriteTimeout)
+	defer timer.Stop()
+
+	select {
+	case c.out <- m:
+		return nil
+	case <-c.closing:
+		return ErrClosed
+	case <-timer.C:
+		_ = c.Close(CloseTryAgainLater, "slow consumer")
+		return ErrSlowConsumer
+	}
+}
+
+func (c *Conn) SendText(s string) error {
+	return c.Send(Message{Type: TextMessage, Data: []byte(s)})
+}
+
+func (c *Conn) SendJSON(v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Send(Message{Type: TextMessage, Data: buf})
+}
+
+// Close starts closing handshake: messages already queued are written,
+// then close frame is sent and connection waits for client close frame
+// up to CloseTimeout. Done is closed when handshake completes.
+func (c *Conn) Close(code int, reason string) error {
+	initiated := false
+	c.closeOnce.Do(func() {
+		initiated = true
+
+		c.lock.Lock()
+		c.closeCode, c.closeReason = code, reason
+		c.lock.Unlock()
+
+		c.closeDeadline = time.Now().Add(c.config.CloseTimeout)
+		close(c.closing)
+		_ = c.conn.SetReadDeadline(c.closeDeadline)
+	})
+	if !initiated {
+		return ErrClosed
+	}
+	return nil
+}
+
+// finish closes underlying connection, it is safe to call multiple times.
+func (c *Conn) finish() {
+	c.doneOnce.Do(func() {
+		c.lock.Lock()
+		if c.closeCode == 0 {
+			c.closeCode = CloseAbnormal
+		}
+		c.lock.Unlock()
+
+		_ = c.conn.Close()
+		c.cancel()
+		close(c.done)
+	})
+}
+
+//
+
+func (c *Conn) readLoop() {
+	defer c.finish()
+	defer close(c.in)
+
+	for {
+		m, err := c.readMessage()
+		if err != nil {
+			var perr ErrProtocol
+			if errors.As(err, &perr) {
+				_ = c.Close(perr.Code, perr.Reason)
+				c.awaitWritten()
+			}
+			return
+		}
+		if m == nil { // close handshake completed
+			return
+		}
+
+		select {
+		case <-c.closing:
+			continue // closing, message is dropped
+		default:
+		}
+		c.metrics.message(directionIn)
+
+		select {
+		case c.in <- *m:
+		case <-c.closing:
+		}
+	}
+}
+
+// readMessage reads frames until the whole data message is assembled,
+// control frames are handled in between, nil message means close frame was received.
+func (c *Conn) readMessage() (*Message, error) {
+	var (
+		m          *Message
+		fragmented bool
+	)
+
+	for {
+		deadline := time.Now().Add(c.config.PongTimeout)
+		select {
+		case <-c.closing:
+			deadline = c.closeDeadline
+		default:
+		}
+		_ = c.conn.SetReadDeadline(deadline)
+
+		f, err := readFrame(c.r, c.config.MaxMessageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		switch f.opcode {
+		case opPing:
+			select {
+			case c.control <- frame{opcode: opPong, payload: f.payload}:
+			default: // pong is not queued, client will ping again
+			}
+			continue
+		case opPong:
+			continue // read deadline is extended by any frame
+		case opClose:
+			code, reason, err := parseClosePayload(f.payload)
+			if err != nil {
+				return nil, err
+			}
+			_ = c.Close(code, reason) // echo close frame unless closing already
+			c.awaitWritten()
+			return nil, nil
+		case opText, opBinary:
+			if fragmented {
+				return nil, ErrProtocol{Code: CloseProtocolError, Reason: "continuation frame expected"}
+			}
+			m = &Message{Type: MessageType(f.opcode), Data: f.payload}
+		case opContinuation:
+			if !fragmented {
+				return nil, ErrProtocol{Code: CloseProtocolError, Reason: "unexpected continuation frame"}
+			}
+			if int64(len(m.Data)+len(f.payload)) > c.config.MaxMessageSize {
+				return nil, ErrProtocol{Code: CloseMessageTooBig, Reason: "message is too large"}
+			}
+			m.Data = append(m.Data, f.payload...)
+		default:
+			return nil, ErrProtocol{Code: CloseProtocolError, Reason: "unknown opcode"}
+		}
+
+		fragmented = !f.fin
+		if fragmented {
+			continue
+		}
+		if m.Type == TextMessage && !utf8.Valid(m.Data) {
+			return nil, ErrProtocol{Code: CloseInvalidPayload, Reason: "text message is not valid UTF-8"}
+		}
+		return m, nil
+	}
+}
+
+func (c *Conn) awaitWritten() {
+	timer := time.NewTimer(c.config.CloseTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-c.written:
+	case <-timer.C:
+	}
+}
+
+//
+
+func (c *Conn) writeLoop() {
+	defer close(c.written)
+
+	ticker := time.NewTicker(c.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-c.done:
+			return
+		case <-c.closing:
+			c.writeClose()
+			return
+		case f := <-c.control:
+			err = c.write(f.opcode, f.payload, time.Now().Add(c.config.WriteTimeout))
+		case m := <-c.out:
+			err = c.write(byte(m.Type), m.Data, time.Now().Add(c.config.WriteTimeout))
+			c.metrics.message(directionOut)
+		case <-ticker.C:
+			err = c.write(opPing, nil, time.Now().Add(c.config.WriteTimeout))
+		}
+		if err != nil {
+			c.finish()
+			return
+		}
+	}
+}
+
+// writeClose flushes queued messages (unless client is too slow) and sends close frame.
+func (c *Conn) writeClose() {
+	code, reason := c.CloseStatus()
+
+	if code != CloseTryAgainLater {
+	drain:
+		for {
+			select {
+			case m := <-c.out:
+				err := c.write(byte(m.Type), m.Data, c.closeDeadline)
+				if err != nil {
+					c.finish()
+					return
+				}
+				c.metrics.message(directionOut)
+			default:
+				break drain
+			}
+		}
+	}
+
+	err := c.write(opClose, closePayload(code, reason), c.closeDeadline)
+	if err != nil {
+		c.finish()
+	}
+}
+
+func (c *Conn) write(opcode byte, payload []byte, deadline time.Time) error {
+	_ = c.conn.SetWriteDeadline(deadline)
+	return writeFrame(c.w, opcode, payload)
+}
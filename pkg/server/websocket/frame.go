@@ -0,0 +1,177 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"unicode/utf8"
+)
+
+// Frame layout is defined in RFC 6455 section 5.2,
+// extensions are not negotiated so reserved bits should be zero.
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	finBit  = 0x80
+	rsvBits = 0x70
+	maskBit = 0x80
+
+	maxControlPayload = 125
+)
+
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+func (f frame) control() bool { return f.opcode&0x8 != 0 }
+
+// ErrProtocol is a protocol violation by peer, connection is closed with Code.
+type ErrProtocol struct {
+	Code   int
+	Reason string
+}
+
+func (e ErrProtocol) Error() string {
+	return "websocket protocol error: " + e.Reason
+}
+
+// readFrame reads single client frame, client frames must be masked.
+// Payload is limited to maxSize bytes.
+func readFrame(r *bufio.Reader, maxSize int64) (frame, error) {
+	var head [2]byte
+	_, err := io.ReadFull(r, head[:])
+	if err != nil {
+		return frame{}, err
+	}
+
+	f := frame{
+		fin:    head[0]&finBit != 0,
+		opcode: head[0] & 0x0f,
+	}
+	switch {
+	case head[0]&rsvBits != 0:
+		return f, ErrProtocol{Code: CloseProtocolError, Reason: "reserved bits are set"}
+	case head[1]&maskBit == 0:
+		return f, ErrProtocol{Code: CloseProtocolError, Reason: "client frame is not masked"}
+	}
+
+	size := int64(head[1] &^ maskBit)
+	switch size {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(r, ext[:])
+		size = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(r, ext[:])
+		size = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if err != nil {
+		return f, err
+	}
+
+	switch {
+	case f.control() && (!f.fin || size > maxControlPayload):
+		return f, ErrProtocol{Code: CloseProtocolError, Reason: "control frame is fragmented or too large"}
+	case size < 0 || size > maxSize:
+		return f, ErrProtocol{Code: CloseMessageTooBig, Reason: "frame is too large"}
+	}
+
+	var mask [4]byte
+	_, err = io.ReadFull(r, mask[:])
+	if err != nil {
+		return f, err
+	}
+
+	f.payload = make([]byte, size)
+	_, err = io.ReadFull(r, f.payload)
+	if err != nil {
+		return f, err
+	}
+	for n := range f.payload {
+		f.payload[n] ^= mask[n%4]
+	}
+
+	return f, nil
+}
+
+// writeFrame writes single unmasked (server) frame and flushes it.
+func writeFrame(w *bufio.Writer, opcode byte, payload []byte) error {
+	w.WriteByte(finBit | opcode)
+
+	size := len(payload)
+	switch {
+	case size < 126:
+		w.WriteByte(byte(size))
+	case size <= 0xffff:
+		var ext [2]byte
+		binary.BigEndian.PutUint16(ext[:], uint16(size))
+		w.WriteByte(126)
+		w.Write(ext[:])
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(size))
+		w.WriteByte(127)
+		w.Write(ext[:])
+	}
+	w.Write(payload)
+
+	return w.Flush()
+}
+
+//
+
+func closePayload(code int, reason string) []byte {
+	if code == CloseNoStatus {
+		return nil
+	}
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+	buf := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(buf, uint16(code))
+	copy(buf[2:], reason)
+	return buf
+}
+
+func parseClosePayload(payload []byte) (int, string, error) {
+	switch {
+	case len(payload) == 0:
+		return CloseNoStatus, "", nil
+	case len(payload) == 1:
+		return 0, "", ErrProtocol{Code: CloseProtocolError, Reason: "close frame payload is truncated"}
+	}
+
+	code := int(binary.BigEndian.Uint16(payload))
+	reason := payload[2:]
+	switch {
+	case !validCloseCode(code):
+		return 0, "", ErrProtocol{Code: CloseProtocolError, Reason: "invalid close code"}
+	case !utf8.Valid(reason):
+		return 0, "", ErrProtocol{Code: CloseInvalidPayload, Reason: "close reason is not valid UTF-8"}
+	}
+	return code, string(reason), nil
+}
+
+// validCloseCode reports whether code could be sent by peer (RFC 6455 section 7.4).
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code < 1000 || code > 1014:
+		return false
+	}
+	switch code {
+	case 1004, CloseNoStatus, CloseAbnormal:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+)
+
+const (
+	directionIn  = "in"
+	directionOut = "out"
+
+	ResultAccepted  = "accepted"
+	ResultHandshake = "handshake"
+	ResultOrigin    = "origin"
+	ResultCSRF      = "csrf"
+	ResultSession   = "session"
+	ResultShutdown  = "shutdown"
+)
+
+type Metrics struct {
+	Connections collector.Gauge
+	Handshakes  *collector.CounterVec
+	Messages    *collector.CounterVec
+}
+
+func NewMetrics(r *registry.Registry, subsystem string) *Metrics {
+	f := registry.NewFactory(r, subsystem)
+	return &Metrics{
+		Connections: f.Gauge(
+			"websocket_connections",
+			"How many websocket connections are open.",
+		),
+		Handshakes: f.CounterVec(
+			"websocket_handshakes_total",
+			"How many websocket handshakes were handled, partitioned by result.",
+			"result",
+		),
+		Messages: f.CounterVec(
+			"websocket_messages_total",
+			"How many websocket data messages were transferred, partitioned by direction.",
+			"direction",
+		),
+	}
+}
+
+// methods below are nil-safe, so upgrader works without metrics
+
+func (m *Metrics) handshake(result string) {
+	if m != nil {
+		m.Handshakes.WithLabelValues(result).Inc()
+	}
+}
+
+func (m *Metrics) message(direction string) {
+	if m != nil {
+		m.Messages.WithLabelValues(direction).Inc()
+	}
+}
+
+func (m *Metrics) connections(delta float64) {
+	if m != nil {
+		m.Connections.Add(delta)
+	}
+}
@@ -0,0 +1,419 @@
+package websocket
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/csrf"
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+	"git.backbone/corpix/goboilerplate/pkg/server/session"
+)
+
+const (
+	HeaderConnection           = "Connection"
+	HeaderSecWebSocketKey      = "Sec-WebSocket-Key"
+	HeaderSecWebSocketAccept   = "Sec-WebSocket-Accept"
+	HeaderSecWebSocketVersion  = "Sec-WebSocket-Version"
+	HeaderSecWebSocketProtocol = "Sec-WebSocket-Protocol"
+
+	Version = "13"
+
+	KindHandshake    = "websocket_handshake"
+	KindOrigin       = "websocket_origin"
+	KindUnauthorized = "unauthorized"
+	KindUnavailable  = "unavailable"
+
+	// acceptGUID is appended to the client key to compute accept key (RFC 6455 section 1.3).
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	keySize    = 16
+)
+
+type (
+	// Handler serves upgraded connection, connection is closed when handler returns
+	// (with CloseInternalError when error is returned).
+	Handler = func(*Conn) error
+	// Authenticator decides whether handshake is allowed for session,
+	// session is nil when there is no session store in context.
+	Authenticator = func(c echo.Context, s *session.Session) error
+	Option        = func(*Upgrader)
+
+	// Upgrader validates handshakes, upgrades connections and keeps track of them,
+	// so they could be closed on shutdown (http.Server.Shutdown does not wait for
+	// hijacked connections).
+	Upgrader struct {
+		config       Config
+		metrics      *Metrics
+		running      *sync.WaitGroup
+		authenticate Authenticator
+		origins      map[string]struct{}
+
+		lock   sync.Mutex
+		wg     sync.WaitGroup
+		conns  map[*Conn]struct{}
+		closed bool
+	}
+)
+
+// Handler upgrades connection and serves it with fn.
+func (u *Upgrader) Handler(fn Handler) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		conn, err := u.Upgrade(c)
+		if err != nil {
+			return err
+		}
+
+		err = fn(conn)
+		if err != nil {
+			_ = conn.Close(CloseInternalError, "")
+		} else {
+			_ = conn.Close(CloseNormal, "")
+		}
+		<-conn.Done()
+
+		return err
+	}
+}
+
+// Upgrade validates handshake and hijacks connection, response is marked as committed.
+// Handshake is checked in order: protocol, origin, CSRF token, session.
+// Headers already set on response (request id, cookies) are sent with handshake response.
+func (u *Upgrader) Upgrade(c echo.Context) (*Conn, error) {
+	if u.isClosed() {
+		u.metrics.handshake(ResultShutdown)
+		return nil, serverErrors.NewError(
+			http.StatusServiceUnavailable, "server is shutting down",
+			nil, nil,
+		).WithKind(KindUnavailable)
+	}
+
+	req := c.Request()
+	res := c.Response()
+
+	key, err := u.checkHandshake(req, res.Header())
+	if err != nil {
+		u.metrics.handshake(ResultHandshake)
+		return nil, err
+	}
+	err = u.checkOrigin(req)
+	if err != nil {
+		u.metrics.handshake(ResultOrigin)
+		return nil, err
+	}
+	err = u.checkCSRF(c)
+	if err != nil {
+		u.metrics.handshake(ResultCSRF)
+		return nil, err
+	}
+	s, err := u.checkSession(c)
+	if err != nil {
+		u.metrics.handshake(ResultSession)
+		return nil, err
+	}
+
+	hijacker, ok := res.Writer.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("response writer does not support hijacking")
+	}
+
+	subprotocol := u.subprotocol(req)
+
+	h := res.Header()
+	h.Set(echo.HeaderUpgrade, "websocket")
+	h.Set(HeaderConnection, "Upgrade")
+	h.Set(HeaderSecWebSocketAccept, acceptKey(key))
+	if subprotocol != "" {
+		h.Set(HeaderSecWebSocketProtocol, subprotocol)
+	}
+
+	nc, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hijack connection")
+	}
+
+	_ = nc.SetWriteDeadline(time.Now().Add(u.config.WriteTimeout))
+	_, err = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	if err == nil {
+		err = h.Write(rw)
+	}
+	if err == nil {
+		_, err = rw.WriteString("\r\n")
+	}
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		_ = nc.Close()
+		return nil, errors.Wrap(err, "failed to write handshake response")
+	}
+
+	// response is written, nothing should be written by middlewares or error handler
+	res.Status = http.StatusSwitchingProtocols
+	res.Committed = true
+
+	conn := newConn(u.config, nc, rw, req, s, subprotocol, u.metrics)
+	u.track(conn)
+	conn.start()
+
+	u.metrics.handshake(ResultAccepted)
+
+	return conn, nil
+}
+
+// Shutdown closes connections with CloseGoingAway and waits for closing handshakes,
+// when ctx is done remaining connections are dropped.
+// Upgrades are rejected after Shutdown.
+func (u *Upgrader) Shutdown(ctx context.Context) error {
+	u.lock.Lock()
+	u.closed = true
+	conns := make([]*Conn, 0, len(u.conns))
+	for conn := range u.conns {
+		conns = append(conns, conn)
+	}
+	u.lock.Unlock()
+
+	for _, conn := range conns {
+		_ = conn.Close(CloseGoingAway, "server shutdown")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		u.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, conn := range conns {
+			conn.finish()
+		}
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Len returns number of open connections.
+func (u *Upgrader) Len() int {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return len(u.conns)
+}
+
+func (u *Upgrader) isClosed() bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.closed
+}
+
+func (u *Upgrader) track(conn *Conn) {
+	u.lock.Lock()
+	closed := u.closed
+	u.conns[conn] = struct{}{}
+	u.wg.Add(1)
+	if u.running != nil {
+		u.running.Add(1)
+	}
+	u.lock.Unlock()
+
+	u.metrics.connections(1)
+	if closed {
+		// shutdown started while handshake was in progress
+		_ = conn.Close(CloseGoingAway, "server shutdown")
+	}
+
+	go func() {
+		<-conn.Done()
+
+		u.lock.Lock()
+		delete(u.conns, conn)
+		u.lock.Unlock()
+
+		u.metrics.connections(-1)
+		u.wg.Done()
+		if u.running != nil {
+			u.running.Done()
+		}
+	}()
+}
+
+//
+
+func (u *Upgrader) checkHandshake(req *http.Request, h http.Header) (string, error) {
+	fail := func(code int, text string) (string, error) {
+		return "", serverErrors.NewError(code, text, nil, nil).WithKind(KindHandshake)
+	}
+
+	switch {
+	case req.Method != http.MethodGet:
+		return fail(http.StatusMethodNotAllowed, "websocket handshake requires GET method")
+	case !headerHasToken(req.Header, echo.HeaderUpgrade, "websocket"):
+		h.Set(echo.HeaderUpgrade, "websocket")
+		return fail(http.StatusUpgradeRequired, "websocket upgrade is required")
+	case !headerHasToken(req.Header, HeaderConnection, "upgrade"):
+		return fail(http.StatusBadRequest, "connection header should contain upgrade token")
+	case req.Header.Get(HeaderSecWebSocketVersion) != Version:
+		h.Set(HeaderSecWebSocketVersion, Version)
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+
+	key := req.Header.Get(HeaderSecWebSocketKey)
+	buf, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(buf) != keySize {
+		return fail(http.StatusBadRequest, "invalid websocket key")
+	}
+	return key, nil
+}
+
+// checkOrigin allows same origin and configured origins,
+// scheme is not compared for same origin because TLS could be terminated by proxy.
+func (u *Upgrader) checkOrigin(req *http.Request) error {
+	fail := func(text string) error {
+		return serverErrors.NewError(http.StatusForbidden, text, nil, nil).WithKind(KindOrigin)
+	}
+
+	origin := req.Header.Get(echo.HeaderOrigin)
+	if origin == "" {
+		if u.config.RequireOrigin {
+			return fail("origin header is required")
+		}
+		return nil
+	}
+
+	o, err := url.Parse(origin)
+	if err != nil || o.Host == "" {
+		return fail("origin is invalid")
+	}
+	if strings.EqualFold(o.Host, req.Host) {
+		return nil
+	}
+	if _, ok := u.origins[strings.ToLower(o.Scheme+"://"+o.Host)]; ok {
+		return nil
+	}
+	return fail("origin is not allowed")
+}
+
+func (u *Upgrader) checkCSRF(c echo.Context) error {
+	if !u.config.CSRF {
+		return nil
+	}
+	t, ok := c.Get(csrf.ContextKey).(*csrf.CSRF)
+	if !ok {
+		return errors.Errorf(
+			"failed to load csrf from context key %q, csrf middleware is required",
+			csrf.ContextKey,
+		)
+	}
+	return t.ValidateContext(c)
+}
+
+func (u *Upgrader) checkSession(c echo.Context) (*session.Session, error) {
+	var s *session.Session
+	if store, ok := session.GetStore(c); ok {
+		s = store.Session()
+	}
+	if s == nil && u.config.RequireSession {
+		return nil, serverErrors.NewError(
+			http.StatusUnauthorized, "session is required",
+			nil, nil,
+		).WithKind(KindUnauthorized)
+	}
+	if u.authenticate != nil {
+		err := u.authenticate(c, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// subprotocol picks first protocol offered by client which is supported by server.
+func (u *Upgrader) subprotocol(req *http.Request) string {
+	for _, offered := range headerTokens(req.Header, HeaderSecWebSocketProtocol) {
+		for _, supported := range u.config.Subprotocols {
+			if offered == supported {
+				return offered
+			}
+		}
+	}
+	return ""
+}
+
+//
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerTokens(h http.Header, name string) []string {
+	tokens := []string{}
+	for _, value := range h.Values(name) {
+		for _, token := range strings.Split(value, ",") {
+			token = strings.TrimSpace(token)
+			if token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerHasToken(h http.Header, name string, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+//
+
+func WithMetrics(m *Metrics) Option {
+	return func(u *Upgrader) {
+		u.metrics = m
+	}
+}
+
+// WithWaitGroup adds every open connection to wg (usually application running group),
+// so process waits for connections to close before exit.
+func WithWaitGroup(wg *sync.WaitGroup) Option {
+	return func(u *Upgrader) {
+		u.running = wg
+	}
+}
+
+func WithAuthenticator(fn Authenticator) Option {
+	return func(u *Upgrader) {
+		u.authenticate = fn
+	}
+}
+
+func New(c Config, options ...Option) (*Upgrader, error) {
+	u := &Upgrader{
+		config:  c,
+		origins: make(map[string]struct{}, len(c.AllowOrigins)),
+		conns:   map[*Conn]struct{}{},
+	}
+	for _, origin := range c.AllowOrigins {
+		o, err := url.Parse(origin)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse allowed origin %q", origin)
+		}
+		u.origins[strings.ToLower(o.Scheme+"://"+o.Host)] = struct{}{}
+	}
+	for _, option := range options {
+		option(u)
+	}
+	return u, nil
+}
@@ -17,6 +17,12 @@ var (
 	NewGaugeVec     = prometheus.NewGaugeVec
 	NewHistogram    = prometheus.NewHistogram
 	NewHistogramVec = prometheus.NewHistogramVec
+	NewSummary      = prometheus.NewSummary
+	NewSummaryVec   = prometheus.NewSummaryVec
+
+	DefBuckets         = prometheus.DefBuckets
+	LinearBuckets      = prometheus.LinearBuckets
+	ExponentialBuckets = prometheus.ExponentialBuckets
 )
 
 type (
@@ -32,6 +38,15 @@ type (
 	HistogramVec  = prometheus.HistogramVec
 	HistogramOpts = prometheus.HistogramOpts
 
+	Summary     = prometheus.Summary
+	SummaryVec  = prometheus.SummaryVec
+	SummaryOpts = prometheus.SummaryOpts
+
+	ExemplarObserver = prometheus.ExemplarObserver
+
+	Collector              = prometheus.Collector
+	AlreadyRegisteredError = prometheus.AlreadyRegisteredError
+
 	Labels = prometheus.Labels
 )
 
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+const (
+	KindCounter   = "counter"
+	KindGauge     = "gauge"
+	KindHistogram = "histogram"
+	KindSummary   = "summary"
+
+	SuffixTotal = "_total"
+)
+
+var (
+	namePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+	// Units are base unit suffixes (https://prometheus.io/docs/practices/naming/#base-units),
+	// histograms and summaries should end with one of them.
+	Units = []string{
+		"seconds", "bytes", "ratio", "meters", "grams",
+		"joules", "volts", "amperes", "celsius",
+	}
+	// NonBaseUnits are units which should be converted to base units before reporting.
+	NonBaseUnits = []string{
+		"nanoseconds", "microseconds", "milliseconds", "ms", "minutes", "hours", "days",
+		"kilobytes", "megabytes", "gigabytes", "bits", "percent",
+	}
+	// ReservedSuffixes are used by histogram and summary series.
+	ReservedSuffixes = []string{"_bucket", "_count", "_sum"}
+)
+
+func hasUnit(name string, units []string) bool {
+	for _, unit := range units {
+		if strings.HasSuffix(name, "_"+unit) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateName checks metric name follows naming conventions:
+// snake case, counters end with _total, histograms and summaries end with base unit,
+// no non-base units and no reserved suffixes.
+func ValidateName(kind string, name string) error {
+	if !namePattern.MatchString(name) {
+		return errors.Errorf("metric %q name should be lower snake case", name)
+	}
+	for _, suffix := range ReservedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return errors.Errorf("metric %q name should not end with reserved suffix %q", name, suffix)
+		}
+	}
+
+	base := strings.TrimSuffix(name, SuffixTotal)
+	if hasUnit(base, NonBaseUnits) {
+		return errors.Errorf("metric %q name should use base units (one of: %q)", name, Units)
+	}
+
+	switch kind {
+	case KindCounter:
+		if !strings.HasSuffix(name, SuffixTotal) {
+			return errors.Errorf("counter %q name should end with %q", name, SuffixTotal)
+		}
+	case KindGauge:
+		if strings.HasSuffix(name, SuffixTotal) {
+			return errors.Errorf("gauge %q name should not end with %q", name, SuffixTotal)
+		}
+	case KindHistogram, KindSummary:
+		if !hasUnit(name, Units) {
+			return errors.Errorf("%s %q name should end with base unit (one of: %q)", kind, name, Units)
+		}
+	default:
+		return errors.Errorf("unexpected metric kind %q", kind)
+	}
+	return nil
+}
@@ -4,13 +4,26 @@ import (
 	"git.backbone/corpix/goboilerplate/pkg/bus"
 	"git.backbone/corpix/goboilerplate/pkg/errors"
 	"git.backbone/corpix/goboilerplate/pkg/server"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/debug"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/health"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/push"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/statsd"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/trace"
 )
 
 type Config struct {
-	Enable bool           `yaml:"enable"`
-	Addr   string         `yaml:"addr"`
-	Path   string         `yaml:"path"`
-	HTTP   *server.Config `yaml:"http"`
+	Enable bool   `yaml:"enable"`
+	Addr   string `yaml:"addr"`
+	Path   string `yaml:"path"`
+	// OpenMetrics enables OpenMetrics exposition for scrapers which accept it,
+	// it is always enabled with tracing, because exemplars require it.
+	OpenMetrics bool           `yaml:"open-metrics"`
+	HTTP        *server.Config `yaml:"http"`
+	Trace       *trace.Config  `yaml:"trace"`
+	Health      *health.Config `yaml:"health"`
+	Debug       *debug.Config  `yaml:"debug"`
+	Push        *push.Config   `yaml:"push"`
+	StatsD      *statsd.Config `yaml:"statsd"`
 }
 
 func (c *Config) Default() {
@@ -23,6 +36,22 @@ loop:
 			c.Path = "/"
 		case c.HTTP == nil:
 			c.HTTP = &server.Config{}
+		case c.HTTP.Limit == nil:
+			c.HTTP.Limit = &server.LimitConfig{}
+		case c.HTTP.Limit.Body == "":
+			// metrics endpoint expects no request body,
+			// set before http limit defaults are applied
+			c.HTTP.Limit.Body = "0"
+		case c.Trace == nil:
+			c.Trace = &trace.Config{}
+		case c.Health == nil:
+			c.Health = &health.Config{}
+		case c.Debug == nil:
+			c.Debug = &debug.Config{}
+		case c.Push == nil:
+			c.Push = &push.Config{}
+		case c.StatsD == nil:
+			c.StatsD = &statsd.Config{}
 		default:
 			break loop
 		}
@@ -36,6 +65,16 @@ func (c *Config) Validate() error {
 	if c.Path == "" {
 		return errors.New("path should not be empty")
 	}
+	if c.Debug.Enable && c.HTTP.Timeout.Write <= c.Debug.MaxDuration {
+		return errors.Errorf(
+			"http timeout write %s should be greater than debug max-duration %s to capture profiles",
+			c.HTTP.Timeout.Write, c.Debug.MaxDuration,
+		)
+	}
+	switch c.Path {
+	case PathHealth, PathLiveness, PathReadiness:
+		return errors.Errorf("path %q conflicts with health endpoint", c.Path)
+	}
 
 	return nil
 }
@@ -0,0 +1,65 @@
+package debug
+
+import (
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+type Config struct {
+	Enable bool        `yaml:"enable"`
+	Auth   *AuthConfig `yaml:"auth"`
+	// MaxDuration limits duration of CPU profile and trace captures,
+	// it is also used when request has no seconds and should be less than http write timeout.
+	MaxDuration time.Duration `yaml:"max-duration"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.Auth == nil:
+			c.Auth = &AuthConfig{}
+		case c.MaxDuration <= 0:
+			c.MaxDuration = 4 * time.Second
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if !c.Auth.Basic() && len(c.Auth.ClientSubjects) == 0 {
+		return errors.New("auth should define username with password (or password-file) or client-subjects")
+	}
+	return nil
+}
+
+//
+
+// AuthConfig protects debug endpoints with basic auth or client certificates (mTLS),
+// client certificates require telemetry http tls with client-ca.
+type AuthConfig struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password-file"`
+	// ClientSubjects are common names of client certificates allowed to access endpoints.
+	ClientSubjects []string `yaml:"client-subjects"`
+}
+
+func (c *AuthConfig) Basic() bool {
+	return c.Username != "" && (c.Password != "" || c.PasswordFile != "")
+}
+
+func (c *AuthConfig) Validate() error {
+	if c.Password != "" && c.PasswordFile != "" {
+		return errors.New("either password or password-file should be defined, not both")
+	}
+	if c.Username == "" && (c.Password != "" || c.PasswordFile != "") {
+		return errors.New("username should be defined with password")
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package debug
+
+import (
+	"crypto/subtle"
+	"io/ioutil"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rdebug "runtime/debug"
+	rpprof "runtime/pprof"
+	"strconv"
+	"strings"
+
+	echo "github.com/labstack/echo/v4"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/meta"
+	"git.backbone/corpix/goboilerplate/pkg/server"
+	serverErrors "git.backbone/corpix/goboilerplate/pkg/server/errors"
+)
+
+const (
+	// Prefix is fixed because pprof index resolves profile names relative to it.
+	Prefix = "/debug"
+
+	KindUnauthorized = "unauthorized"
+
+	realm = "debug"
+)
+
+// Profiles are runtime profiles served by name (see runtime/pprof.Lookup).
+var Profiles = []string{"allocs", "block", "goroutine", "heap", "mutex", "threadcreate"}
+
+type (
+	Debug struct {
+		config   Config
+		password []byte
+		dump     interface{}
+	}
+
+	// BuildInfo describes running binary.
+	BuildInfo struct {
+		Name         string   `json:"name"`
+		Version      string   `json:"version"`
+		GoVersion    string   `json:"go_version"`
+		OS           string   `json:"os"`
+		Arch         string   `json:"arch"`
+		NumCPU       int      `json:"num_cpu"`
+		GOMAXPROCS   int      `json:"gomaxprocs"`
+		NumGoroutine int      `json:"num_goroutine"`
+		Module       string   `json:"module,omitempty"`
+		Deps         []string `json:"deps,omitempty"`
+	}
+
+	Option = func(*Debug)
+)
+
+// WithConfigDump sets configuration served (with secrets redacted) at /debug/config.
+func WithConfigDump(c interface{}) Option {
+	return func(d *Debug) {
+		d.dump = c
+	}
+}
+
+//
+
+// Authenticate allows requests with valid basic auth credentials
+// or verified client certificate with allowed common name.
+func (d *Debug) Authenticate(next server.HandlerFunc) server.HandlerFunc {
+	return func(c server.Context) error {
+		req := c.Request()
+
+		if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+			cn := req.TLS.VerifiedChains[0][0].Subject.CommonName
+			for _, subject := range d.config.Auth.ClientSubjects {
+				if subject == cn {
+					return next(c)
+				}
+			}
+		}
+
+		if d.config.Auth.Basic() {
+			username, password, ok := req.BasicAuth()
+			if ok &&
+				subtle.ConstantTimeCompare([]byte(username), []byte(d.config.Auth.Username)) == 1 &&
+				subtle.ConstantTimeCompare([]byte(password), d.password) == 1 {
+				return next(c)
+			}
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="`+realm+`"`)
+		}
+
+		return serverErrors.NewError(
+			http.StatusUnauthorized, "",
+			nil, nil,
+		).WithKind(KindUnauthorized)
+	}
+}
+
+// DefaultDuration sets capture duration of profile and trace requests without seconds
+// to configured maximum, otherwise pprof captures 30s which may exceed server write timeout.
+func (d *Debug) DefaultDuration(next server.HandlerFunc) server.HandlerFunc {
+	return func(c server.Context) error {
+		req := c.Request()
+		query := req.URL.Query()
+		if query.Get("seconds") == "" {
+			query.Set("seconds", strconv.FormatFloat(d.config.MaxDuration.Seconds(), 'f', -1, 64))
+			req.URL.RawQuery = query.Encode()
+		}
+		return next(c)
+	}
+}
+
+// LimitDuration rejects profile and trace captures longer than configured maximum.
+func (d *Debug) LimitDuration(next server.HandlerFunc) server.HandlerFunc {
+	return func(c server.Context) error {
+		seconds := c.QueryParam("seconds")
+		if seconds == "" {
+			return next(c)
+		}
+		n, err := strconv.ParseFloat(seconds, 64)
+		if err != nil || n <= 0 || n > d.config.MaxDuration.Seconds() {
+			return serverErrors.NewError(
+				http.StatusBadRequest,
+				"seconds should be a positive number not greater than "+
+					strconv.FormatFloat(d.config.MaxDuration.Seconds(), 'f', -1, 64),
+				err, nil,
+			).WithKind(serverErrors.KindValidation)
+		}
+		return next(c)
+	}
+}
+
+//
+
+func (d *Debug) HandleGoroutines(c server.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+	return rpprof.Lookup("goroutine").WriteTo(c.Response(), 2)
+}
+
+func (d *Debug) HandleBuildInfo(c server.Context) error {
+	info := BuildInfo{
+		Name:         meta.Name,
+		Version:      meta.Version,
+		GoVersion:    runtime.Version(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		NumCPU:       runtime.NumCPU(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		NumGoroutine: runtime.NumGoroutine(),
+	}
+	if bi, ok := rdebug.ReadBuildInfo(); ok {
+		info.Module = bi.Main.Path + "@" + bi.Main.Version
+		info.Deps = make([]string, len(bi.Deps))
+		for n, dep := range bi.Deps {
+			info.Deps[n] = dep.Path + "@" + dep.Version
+		}
+	}
+	return c.JSON(http.StatusOK, info)
+}
+
+func (d *Debug) HandleConfig(c server.Context) error {
+	if d.dump == nil {
+		return serverErrors.NewError(http.StatusNotFound, "configuration dump is not available", nil, nil)
+	}
+	buf, err := Redact(d.dump)
+	if err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, "application/yaml", buf)
+}
+
+// Mount registers debug routes under Prefix:
+//
+//	/debug/pprof/...   - net/http/pprof index, profiles and on-demand trace capture;
+//	/debug/goroutines  - full goroutine dump;
+//	/debug/build       - build information;
+//	/debug/config      - configuration with secrets redacted.
+func (d *Debug) Mount(r server.Router) {
+	g := r.Router(Prefix, d.Authenticate)
+
+	g.GET("/pprof/", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	g.GET("/pprof/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	g.GET("/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.GET("/pprof/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)), d.DefaultDuration, d.LimitDuration)
+	g.GET("/pprof/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)), d.DefaultDuration, d.LimitDuration)
+	for _, name := range Profiles {
+		g.GET("/pprof/"+name, echo.WrapHandler(pprof.Handler(name)), d.LimitDuration)
+	}
+
+	g.GET("/goroutines", d.HandleGoroutines)
+	g.GET("/build", d.HandleBuildInfo)
+	g.GET("/config", d.HandleConfig)
+}
+
+func New(c Config, options ...Option) (*Debug, error) {
+	d := &Debug{config: c}
+
+	switch {
+	case c.Auth.PasswordFile != "":
+		buf, err := ioutil.ReadFile(c.Auth.PasswordFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load password-file: %q", c.Auth.PasswordFile)
+		}
+		d.password = []byte(strings.TrimSpace(string(buf)))
+	default:
+		d.password = []byte(c.Auth.Password)
+	}
+
+	for _, option := range options {
+		option(d)
+	}
+
+	return d, nil
+}
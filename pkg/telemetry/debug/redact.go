@@ -0,0 +1,113 @@
+package debug
+
+import (
+	"net/url"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const Redacted = "[redacted]"
+
+var (
+	// RedactKeys are configuration keys whose values are secrets,
+	// headers are redacted as a whole because they usually carry credentials.
+	RedactKeys = map[string]bool{
+		"password":    true,
+		"secret":      true,
+		"token":       true,
+		"credentials": true,
+		"headers":     true,
+	}
+	// RedactSuffixes match configuration keys whose values are secrets by suffix.
+	RedactSuffixes = []string{"-password", "-secret", "-token"}
+)
+
+// Redact encodes configuration into YAML with secret values replaced,
+// user info is stripped from values which are URLs.
+func Redact(c interface{}) ([]byte, error) {
+	buf, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree yaml.MapSlice
+	err = yaml.Unmarshal(buf, &tree)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(redact(tree))
+}
+
+// secret reports whether key of the block holds a secret,
+// key is a secret only when block could load it from key-file
+// (other keys, like rate limit key or tls key path, are not secrets).
+func secret(block yaml.MapSlice, key string) bool {
+	if RedactKeys[key] {
+		return true
+	}
+	for _, suffix := range RedactSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	if key == "key" {
+		for _, item := range block {
+			if item.Key == "key-file" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func redact(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case yaml.MapSlice:
+		for n, item := range vv {
+			key, _ := item.Key.(string)
+			if secret(vv, key) && !empty(item.Value) {
+				vv[n].Value = Redacted
+				continue
+			}
+			vv[n].Value = redact(item.Value)
+		}
+		return vv
+	case []interface{}:
+		for n := range vv {
+			vv[n] = redact(vv[n])
+		}
+		return vv
+	case string:
+		return redactURL(vv)
+	default:
+		return v
+	}
+}
+
+// redactURL strips user info (credentials) from URL, other values are returned as is.
+func redactURL(v string) string {
+	u, err := url.Parse(v)
+	if err != nil || u.User == nil || u.Host == "" {
+		return v
+	}
+	u.User = nil
+	return u.String()
+}
+
+// empty values are kept, so it is visible secret is not set.
+func empty(v interface{}) bool {
+	switch vv := v.(type) {
+	case nil:
+		return true
+	case string:
+		return vv == ""
+	case yaml.MapSlice:
+		return len(vv) == 0
+	case []interface{}:
+		return len(vv) == 0
+	default:
+		return false
+	}
+}
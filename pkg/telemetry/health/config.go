@@ -0,0 +1,40 @@
+package health
+
+import (
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+type Config struct {
+	// Timeout is a default check timeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// CacheTTL is a default duration check results are reused for,
+	// zero runs checks on each probe.
+	CacheTTL time.Duration `yaml:"cache-ttl"`
+	// ShutdownDelay is a duration readiness probes fail before components are stopped on shutdown,
+	// it should be long enough for probes to notice failing readiness, zero stops immediately.
+	ShutdownDelay time.Duration `yaml:"shutdown-delay"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.Timeout <= 0:
+			c.Timeout = 5 * time.Second
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.CacheTTL < 0 {
+		return errors.Errorf("cache-ttl should not be negative, got %s", c.CacheTTL)
+	}
+	if c.ShutdownDelay < 0 {
+		return errors.Errorf("shutdown-delay should not be negative, got %s", c.ShutdownDelay)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+type (
+	// CheckFunc returns error when component is unhealthy,
+	// it should respect context deadline.
+	CheckFunc = func(ctx context.Context) error
+
+	// Kind is a set of probes check participates in.
+	Kind uint8
+
+	CheckOption = func(*check)
+
+	check struct {
+		name     string
+		fn       CheckFunc
+		kind     Kind
+		timeout  time.Duration
+		cacheTTL time.Duration
+		critical bool
+
+		mu   sync.Mutex
+		last *Result
+	}
+
+	// Result is a check outcome, non-critical failures degrade status
+	// but do not fail probes.
+	Result struct {
+		Name      string        `json:"name"`
+		Status    Status        `json:"status"`
+		Critical  bool          `json:"critical"`
+		Error     string        `json:"error,omitempty"`
+		Duration  time.Duration `json:"duration_ns"`
+		CheckedAt time.Time     `json:"checked_at"`
+		Cached    bool          `json:"cached,omitempty"`
+	}
+
+	Report struct {
+		Status Status   `json:"status"`
+		Checks []Result `json:"checks"`
+	}
+
+	Status string
+
+	// Health is a registry of named checks components register on start.
+	Health struct {
+		config   Config
+		metrics  *Metrics
+		mu       sync.RWMutex
+		checks   map[string]*check
+		shutdown int32
+	}
+
+	Option = func(*Health)
+)
+
+const (
+	KindLiveness Kind = 1 << iota
+	KindReadiness
+
+	KindAll = KindLiveness | KindReadiness
+
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded"
+	StatusFailing  Status = "failing"
+
+	// ShutdownCheck is a readiness check failing after Shutdown.
+	ShutdownCheck = "shutdown"
+)
+
+var ErrShutdown = errors.New("shutting down")
+
+// WithKind sets probes check participates in, checks are readiness checks by default.
+func WithKind(kind Kind) CheckOption {
+	return func(c *check) {
+		c.kind = kind
+	}
+}
+
+func WithTimeout(timeout time.Duration) CheckOption {
+	return func(c *check) {
+		c.timeout = timeout
+	}
+}
+
+// WithCacheTTL reuses check result for ttl, so frequent probes do not overload dependencies.
+func WithCacheTTL(ttl time.Duration) CheckOption {
+	return func(c *check) {
+		c.cacheTTL = ttl
+	}
+}
+
+// WithCritical sets check criticality, checks are critical by default.
+func WithCritical(critical bool) CheckOption {
+	return func(c *check) {
+		c.critical = critical
+	}
+}
+
+func WithMetrics(m *Metrics) Option {
+	return func(h *Health) {
+		h.metrics = m
+	}
+}
+
+//
+
+func (c *check) run(ctx context.Context) Result {
+	c.mu.Lock() // concurrent probes wait for single check run and share cached result
+	defer c.mu.Unlock()
+
+	if c.last != nil && c.cacheTTL > 0 && time.Since(c.last.CheckedAt) < c.cacheTTL {
+		r := *c.last
+		r.Cached = true
+		return r
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var (
+		start = time.Now()
+		errc  = make(chan error, 1)
+	)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				errc <- errors.Errorf("check panicked: %v", e)
+			}
+		}()
+		errc <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		// check ignoring context is abandoned, its goroutine finishes on its own
+		err = errors.Wrapf(ctx.Err(), "check did not finish in %s", c.timeout)
+	}
+
+	r := Result{
+		Name:      c.name,
+		Status:    StatusOK,
+		Critical:  c.critical,
+		Duration:  time.Since(start),
+		CheckedAt: start,
+	}
+	if err != nil {
+		r.Status = StatusFailing
+		r.Error = err.Error()
+	}
+	c.last = &r
+	return r
+}
+
+//
+
+// Register adds named check, names are unique.
+func (h *Health) Register(name string, fn CheckFunc, options ...CheckOption) error {
+	c := &check{
+		name:     name,
+		fn:       fn,
+		kind:     KindReadiness,
+		timeout:  h.config.Timeout,
+		cacheTTL: h.config.CacheTTL,
+		critical: true,
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.checks[name]; ok || name == ShutdownCheck {
+		return errors.Errorf("check %q is already registered", name)
+	}
+	h.checks[name] = c
+	return nil
+}
+
+func (h *Health) Unregister(name string) {
+	h.mu.Lock()
+	delete(h.checks, name)
+	h.mu.Unlock()
+}
+
+// Shutdown makes readiness probes fail, so traffic is drained
+// from the instance while it is shutting down.
+func (h *Health) Shutdown() {
+	atomic.StoreInt32(&h.shutdown, 1)
+}
+
+// ShutdownDelay is a duration readiness should fail before components are stopped.
+func (h *Health) ShutdownDelay() time.Duration {
+	return h.config.ShutdownDelay
+}
+
+func (h *Health) ShuttingDown() bool {
+	return atomic.LoadInt32(&h.shutdown) == 1
+}
+
+// Run runs checks of the kind concurrently and reports their results sorted by name.
+func (h *Health) Run(ctx context.Context, kind Kind) Report {
+	h.mu.RLock()
+	checks := make([]*check, 0, len(h.checks))
+	for _, c := range h.checks {
+		if c.kind&kind != 0 {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	var (
+		results = make([]Result, len(checks))
+		wg      sync.WaitGroup
+	)
+	for n, c := range checks {
+		wg.Add(1)
+		go func(n int, c *check) {
+			defer wg.Done()
+			results[n] = c.run(ctx)
+		}(n, c)
+	}
+	wg.Wait()
+
+	if kind&KindReadiness != 0 && h.ShuttingDown() {
+		results = append(results, Result{
+			Name:      ShutdownCheck,
+			Status:    StatusFailing,
+			Critical:  true,
+			Error:     ErrShutdown.Error(),
+			CheckedAt: time.Now(),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, r := range results {
+		h.metrics.result(r)
+		if r.Status == StatusOK {
+			continue
+		}
+		if r.Critical {
+			report.Status = StatusFailing
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func New(c Config, options ...Option) *Health {
+	h := &Health{
+		config: c,
+		checks: map[string]*check{},
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
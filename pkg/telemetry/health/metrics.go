@@ -0,0 +1,41 @@
+package health
+
+import (
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+)
+
+type Metrics struct {
+	Status   *collector.GaugeVec
+	Duration *collector.HistogramVec
+}
+
+func (m *Metrics) result(r Result) {
+	if m == nil {
+		return
+	}
+	status := 0.0
+	if r.Status == StatusOK {
+		status = 1
+	}
+	m.Status.WithLabelValues(r.Name).Set(status)
+	if !r.Cached && r.Name != ShutdownCheck {
+		m.Duration.WithLabelValues(r.Name).Observe(r.Duration.Seconds())
+	}
+}
+
+func NewMetrics(r *registry.Registry, subsystem string) *Metrics {
+	f := registry.NewFactory(r, subsystem)
+	return &Metrics{
+		Status: f.GaugeVec(
+			"health_check_status",
+			"Last health check result (1 is ok, 0 is failing), partitioned by check name.",
+			"check",
+		),
+		Duration: f.HistogramVec(
+			"health_check_duration_seconds",
+			"The health check durations in seconds, partitioned by check name.",
+			nil, "check",
+		),
+	}
+}
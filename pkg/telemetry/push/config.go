@@ -0,0 +1,100 @@
+package push
+
+import (
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/meta"
+)
+
+const (
+	MethodPut  = "put"
+	MethodPost = "post"
+)
+
+// Methods are Pushgateway push methods:
+// put replaces all metrics of the group, post replaces only metrics with same names.
+var Methods = map[string]struct{}{
+	MethodPut:  {},
+	MethodPost: {},
+}
+
+type Config struct {
+	Enable bool `yaml:"enable"`
+	// URL is a Pushgateway base URL.
+	URL string `yaml:"url"`
+	Job string `yaml:"job"`
+	// Grouping are additional grouping key labels, instance defaults to hostname.
+	Grouping map[string]string `yaml:"grouping"`
+	// Interval defines how often metrics are pushed,
+	// zero pushes metrics only on shutdown (useful for batch jobs).
+	Interval time.Duration     `yaml:"interval"`
+	Timeout  time.Duration     `yaml:"timeout"`
+	Method   string            `yaml:"method"`
+	Headers  map[string]string `yaml:"headers"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.URL == "":
+			c.URL = "http://127.0.0.1:9091"
+		case c.Job == "":
+			c.Job = meta.Name
+		case c.Grouping == nil:
+			hostname, _ := os.Hostname()
+			c.Grouping = map[string]string{"instance": hostname}
+		case c.Timeout <= 0:
+			c.Timeout = 10 * time.Second
+		case c.Method == "":
+			c.Method = MethodPut
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse url %q", c.URL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("url %q scheme should be http or https", c.URL)
+	}
+	if c.Job == "" {
+		return errors.New("job should not be empty")
+	}
+	if c.Interval < 0 {
+		return errors.New("interval should not be negative")
+	}
+	for name, value := range c.Grouping {
+		if name == "job" || strings.HasPrefix(name, "__") {
+			return errors.Errorf("grouping label %q is reserved", name)
+		}
+		if value == "" {
+			return errors.Errorf("grouping label %q value should not be empty", name)
+		}
+	}
+	if _, ok := Methods[c.Method]; !ok {
+		available := make([]string, 0, len(Methods))
+		for k := range Methods {
+			available = append(available, k)
+		}
+		sort.Strings(available)
+
+		return errors.Errorf(
+			"unexpected method %q, expected one of: %q",
+			c.Method, available,
+		)
+	}
+	return nil
+}
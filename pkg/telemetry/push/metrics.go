@@ -0,0 +1,33 @@
+package push
+
+import (
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+)
+
+const (
+	ResultPushed = "pushed"
+	ResultFailed = "failed"
+)
+
+type Metrics struct {
+	Pushes *collector.CounterVec
+}
+
+func (m *Metrics) push(result string) {
+	if m == nil {
+		return
+	}
+	m.Pushes.WithLabelValues(result).Inc()
+}
+
+func NewMetrics(r *registry.Registry, subsystem string) *Metrics {
+	f := registry.NewFactory(r, subsystem)
+	return &Metrics{
+		Pushes: f.CounterVec(
+			"push_total",
+			"How many times metrics were pushed to pushgateway, partitioned by result.",
+			"result",
+		),
+	}
+}
@@ -0,0 +1,179 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/log"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+)
+
+// Pusher pushes registry metrics into Pushgateway,
+// so metrics of processes exiting before scrape are not lost.
+type Pusher struct {
+	config  Config
+	log     log.Logger
+	reg     *registry.Registry
+	client  *http.Client
+	url     string
+	metrics *Metrics
+}
+
+type Option = func(*Pusher)
+
+func WithMetrics(m *Metrics) Option {
+	return func(p *Pusher) {
+		p.metrics = m
+	}
+}
+
+// unreservedPath reports whether value consists only of unreserved URL characters (RFC 3986 section 2.3).
+func unreservedPath(value string) bool {
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '-', r == '.', r == '_', r == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// groupingPath encodes grouping key into URL path,
+// values with characters which are not unreserved (or empty) are base64 encoded as Pushgateway expects,
+// empty value is encoded as "=".
+func groupingPath(job string, grouping map[string]string) string {
+	var (
+		b     strings.Builder
+		names = make([]string, 0, len(grouping))
+	)
+	segment := func(name string, value string) {
+		name = url.PathEscape(name)
+		switch {
+		case value == "":
+			b.WriteString("/" + name + "@base64/=")
+		case !unreservedPath(value):
+			b.WriteString("/" + name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value)))
+		default:
+			b.WriteString("/" + name + "/" + value)
+		}
+	}
+
+	b.WriteString("/metrics")
+	segment("job", job)
+	for name := range grouping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		segment(name, grouping[name])
+	}
+	return b.String()
+}
+
+// Push gathers and pushes metrics once.
+func (p *Pusher) Push(ctx context.Context) error {
+	err := p.push(ctx)
+	if err != nil {
+		p.metrics.push(ResultFailed)
+		return err
+	}
+	p.metrics.push(ResultPushed)
+	return nil
+}
+
+func (p *Pusher) push(ctx context.Context) error {
+	mfs, err := p.reg.Gather()
+	if err != nil {
+		return errors.Wrap(err, "failed to gather metrics")
+	}
+
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtText)
+	for _, mf := range mfs {
+		err = enc.Encode(mf)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode metric family %q", mf.GetName())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(p.config.Method), p.url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", string(expfmt.FmtText))
+	for k, v := range p.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return errors.Errorf(
+			"pushgateway responded with status %d: %s",
+			res.StatusCode, bytes.TrimSpace(body),
+		)
+	}
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	return nil
+}
+
+// Run pushes metrics each interval until done is closed, then pushes them last time.
+func (p *Pusher) Run(done <-chan struct{}) {
+	var tick <-chan time.Time
+	if p.config.Interval > 0 {
+		ticker := time.NewTicker(p.config.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			err := p.Push(context.Background())
+			if err != nil {
+				p.log.Warn().Err(err).Msg("failed to push metrics")
+			}
+		case <-done:
+			err := p.Push(context.Background())
+			if err != nil {
+				p.log.Error().Err(err).Msg("failed to push metrics on shutdown")
+			}
+			return
+		}
+	}
+}
+
+func New(c Config, l log.Logger, r *registry.Registry, options ...Option) *Pusher {
+	p := &Pusher{
+		config: c,
+		log:    l.With().Str("component", "push").Logger(),
+		reg:    r,
+		client: &http.Client{Timeout: c.Timeout},
+		url:    strings.TrimSuffix(c.URL, "/") + groupingPath(c.Job, c.Grouping),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
@@ -0,0 +1,139 @@
+package registry
+
+import (
+	"fmt"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
+)
+
+// Factory creates metrics named after subsystem and registers them,
+// metrics which are already registered (for example by another server
+// with the same subsystem) are returned instead of new ones.
+// Invalid names and conflicting registrations are programming errors, so they panic.
+type Factory struct {
+	registry  *Registry
+	subsystem string
+}
+
+// Name returns full metric name (see collector.Name).
+func (f *Factory) Name(name string, rest ...string) string {
+	return collector.Name(f.subsystem, name, rest...)
+}
+
+func (f *Factory) register(d Descriptor, c collector.Collector) collector.Collector {
+	err := collector.ValidateName(d.Kind, d.Name)
+	if err != nil {
+		panic(err)
+	}
+
+	err = f.registry.Register(c)
+	if err != nil {
+		var registered collector.AlreadyRegisteredError
+		if !errors.As(err, &registered) {
+			panic(errors.Wrapf(err, "failed to register %s %q", d.Kind, d.Name))
+		}
+		c = registered.ExistingCollector
+	}
+
+	f.registry.describe(d)
+	return c
+}
+
+func mismatch(name string, c collector.Collector) error {
+	return errors.Errorf("metric %q is already registered as %s", name, fmt.Sprintf("%T", c))
+}
+
+//
+
+func (f *Factory) Counter(name string, help string) collector.Counter {
+	d := Descriptor{Name: f.Name(name), Kind: collector.KindCounter, Help: help, Labels: []string{}}
+	c := f.register(d, collector.NewCounter(collector.CounterOpts{Name: d.Name, Help: help}))
+	m, ok := c.(collector.Counter)
+	if !ok {
+		panic(mismatch(d.Name, c))
+	}
+	return m
+}
+
+func (f *Factory) CounterVec(name string, help string, labels ...string) *collector.CounterVec {
+	d := Descriptor{Name: f.Name(name), Kind: collector.KindCounter, Help: help, Labels: labels}
+	c := f.register(d, collector.NewCounterVec(collector.CounterOpts{Name: d.Name, Help: help}, labels))
+	m, ok := c.(*collector.CounterVec)
+	if !ok {
+		panic(mismatch(d.Name, c))
+	}
+	return m
+}
+
+func (f *Factory) Gauge(name string, help string) collector.Gauge {
+	d := Descriptor{Name: f.Name(name), Kind: collector.KindGauge, Help: help, Labels: []string{}}
+	c := f.register(d, collector.NewGauge(collector.GaugeOpts{Name: d.Name, Help: help}))
+	m, ok := c.(collector.Gauge)
+	if !ok {
+		panic(mismatch(d.Name, c))
+	}
+	return m
+}
+
+func (f *Factory) GaugeVec(name string, help string, labels ...string) *collector.GaugeVec {
+	d := Descriptor{Name: f.Name(name), Kind: collector.KindGauge, Help: help, Labels: labels}
+	c := f.register(d, collector.NewGaugeVec(collector.GaugeOpts{Name: d.Name, Help: help}, labels))
+	m, ok := c.(*collector.GaugeVec)
+	if !ok {
+		panic(mismatch(d.Name, c))
+	}
+	return m
+}
+
+// Histogram creates histogram, nil buckets are collector.DefBuckets.
+func (f *Factory) Histogram(name string, help string, buckets []float64) collector.Histogram {
+	if buckets == nil {
+		buckets = collector.DefBuckets
+	}
+	d := Descriptor{Name: f.Name(name), Kind: collector.KindHistogram, Help: help, Labels: []string{}, Buckets: buckets}
+	c := f.register(d, collector.NewHistogram(collector.HistogramOpts{Name: d.Name, Help: help, Buckets: buckets}))
+	m, ok := c.(collector.Histogram)
+	if !ok {
+		panic(mismatch(d.Name, c))
+	}
+	return m
+}
+
+func (f *Factory) HistogramVec(name string, help string, buckets []float64, labels ...string) *collector.HistogramVec {
+	if buckets == nil {
+		buckets = collector.DefBuckets
+	}
+	d := Descriptor{Name: f.Name(name), Kind: collector.KindHistogram, Help: help, Labels: labels, Buckets: buckets}
+	c := f.register(d, collector.NewHistogramVec(collector.HistogramOpts{Name: d.Name, Help: help, Buckets: buckets}, labels))
+	m, ok := c.(*collector.HistogramVec)
+	if !ok {
+		panic(mismatch(d.Name, c))
+	}
+	return m
+}
+
+// Summary creates summary, objectives are quantiles with their allowed errors.
+func (f *Factory) Summary(name string, help string, objectives map[float64]float64) collector.Summary {
+	d := Descriptor{Name: f.Name(name), Kind: collector.KindSummary, Help: help, Labels: []string{}}
+	c := f.register(d, collector.NewSummary(collector.SummaryOpts{Name: d.Name, Help: help, Objectives: objectives}))
+	m, ok := c.(collector.Summary)
+	if !ok {
+		panic(mismatch(d.Name, c))
+	}
+	return m
+}
+
+func (f *Factory) SummaryVec(name string, help string, objectives map[float64]float64, labels ...string) *collector.SummaryVec {
+	d := Descriptor{Name: f.Name(name), Kind: collector.KindSummary, Help: help, Labels: labels}
+	c := f.register(d, collector.NewSummaryVec(collector.SummaryOpts{Name: d.Name, Help: help, Objectives: objectives}, labels))
+	m, ok := c.(*collector.SummaryVec)
+	if !ok {
+		panic(mismatch(d.Name, c))
+	}
+	return m
+}
+
+func NewFactory(r *Registry, subsystem string) *Factory {
+	return &Factory{registry: r, subsystem: subsystem}
+}
@@ -1,13 +1,33 @@
 package registry
 
 import (
+	"sort"
+	"strings"
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 
 	"git.backbone/corpix/goboilerplate/pkg/meta"
 	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
 )
 
-type Registry = prometheus.Registry
+// Registry is a prometheus registry which keeps descriptors of metrics
+// created with Factory, so metrics could be listed before they are observed.
+type Registry struct {
+	*prometheus.Registry
+
+	mu          sync.RWMutex
+	descriptors map[string]Descriptor
+}
+
+// Descriptor describes registered metric.
+type Descriptor struct {
+	Name    string    `json:"name"`
+	Kind    string    `json:"kind"`
+	Help    string    `json:"help"`
+	Labels  []string  `json:"labels"`
+	Buckets []float64 `json:"buckets,omitempty"`
+}
 
 var DefaultRegistry = NewRegistry()
 
@@ -30,9 +50,58 @@ func init() {
 			),
 		},
 	)
+}
+
+func (r *Registry) describe(d Descriptor) {
+	r.mu.Lock()
+	r.descriptors[d.Name] = d
+	r.mu.Unlock()
+}
+
+// Descriptors lists metrics created with Factory and
+// metrics of other collectors which were gathered, sorted by name.
+func (r *Registry) Descriptors() ([]Descriptor, error) {
+	mfs, err := r.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	descriptors := make(map[string]Descriptor, len(r.descriptors)+len(mfs))
+	for name, d := range r.descriptors {
+		descriptors[name] = d
+	}
+	r.mu.RUnlock()
+
+	for _, mf := range mfs {
+		if _, ok := descriptors[mf.GetName()]; ok {
+			continue
+		}
+		d := Descriptor{
+			Name:   mf.GetName(),
+			Kind:   strings.ToLower(mf.GetType().String()),
+			Help:   mf.GetHelp(),
+			Labels: []string{},
+		}
+		if len(mf.GetMetric()) > 0 {
+			for _, l := range mf.GetMetric()[0].GetLabel() {
+				d.Labels = append(d.Labels, l.GetName())
+			}
+		}
+		descriptors[d.Name] = d
+	}
 
+	result := make([]Descriptor, 0, len(descriptors))
+	for _, d := range descriptors {
+		result = append(result, d)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
 }
 
 func NewRegistry() *Registry {
-	return prometheus.NewRegistry()
+	return &Registry{
+		Registry:    prometheus.NewRegistry(),
+		descriptors: map[string]Descriptor{},
+	}
 }
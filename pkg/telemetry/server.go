@@ -2,14 +2,16 @@ package telemetry
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 
-	echomw "github.com/labstack/echo/v4/middleware"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"git.backbone/corpix/goboilerplate/pkg/log"
 	"git.backbone/corpix/goboilerplate/pkg/server"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/debug"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/health"
 	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
 )
 
@@ -27,15 +29,11 @@ type Server struct {
 	log     log.Logger
 	srv     *server.Server
 	handler http.Handler
+	health  *health.Health
 }
 
 func (s *Server) ListenAndServe() error {
-	err := s.srv.StartServer(
-		server.NewHTTPServer(
-			s.config.Addr,
-			server.HTTPTimeoutOption(*s.config.HTTP.Timeout),
-		),
-	)
+	err := s.srv.StartServer(s.srv.NewHTTPServer(s.config.Addr))
 	if err == http.ErrServerClosed {
 		s.log.
 			Warn().
@@ -55,6 +53,43 @@ func (s *Server) Handle(ctx server.Context) error {
 	return nil
 }
 
+// HandleHealth responds with JSON report of checks of the kind,
+// failing report responds with 503 so probes fail.
+func (s *Server) HandleHealth(kind health.Kind) server.HandlerFunc {
+	return func(ctx server.Context) error {
+		report := s.health.Run(ctx.Request().Context(), kind)
+
+		code := http.StatusOK
+		if report.Status == health.StatusFailing {
+			code = http.StatusServiceUnavailable
+		}
+		ctx.Response().Header().Set("Cache-Control", "no-store")
+		return ctx.JSON(code, report)
+	}
+}
+
+// MountDebug mounts debug endpoints (pprof, goroutine dump, build info and config dump),
+// they are protected with their own authentication.
+func (s *Server) MountDebug(d *debug.Debug) {
+	d.Mount(s.srv)
+}
+
+// WatchTLS reloads TLS certificates on file change until done is closed.
+func (s *Server) WatchTLS(done <-chan struct{}) {
+	if s.srv.TLS == nil {
+		return
+	}
+	s.srv.TLS.Watch(done, s.log)
+}
+
+// ReloadTLS reloads TLS certificates, non-nil c replaces TLS configuration.
+func (s *Server) ReloadTLS(c *server.TLSConfig) error {
+	if s.srv.TLS == nil {
+		return nil
+	}
+	return s.srv.TLS.Reload(c)
+}
+
 func (s *Server) Close() error {
 	err := s.srv.Close()
 	if err != nil {
@@ -68,7 +103,7 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return s.srv.Shutdown(ctx)
 }
 
-func New(c Config, l log.Logger, r *Registry, lr Listener) (*Server, error) {
+func New(c Config, l log.Logger, r *Registry, h *health.Health, lr Listener) (*Server, error) {
 	var addr string
 
 	if lr != nil {
@@ -79,29 +114,43 @@ func New(c Config, l log.Logger, r *Registry, lr Listener) (*Server, error) {
 
 	l = l.With().Str("component", Subsystem).Str("listener", addr).Logger()
 
-	h := promhttp.InstrumentMetricHandler(
+	mh := promhttp.InstrumentMetricHandler(
 		r,
 		promhttp.HandlerFor(
 			r,
-			promhttp.HandlerOpts{ErrorLog: log.Std(l)},
+			promhttp.HandlerOpts{
+				ErrorLog: log.Std(l),
+				// exemplars are exposed only with OpenMetrics format
+				EnableOpenMetrics: c.OpenMetrics || c.Trace.Enable,
+			},
 		),
 	)
 
-	e, err := server.New(*c.HTTP, Subsystem, "", l, r)
+	// NOTE: telemetry server requests are not traced, scrapes would flood traces
+	e, err := server.New(*c.HTTP, Subsystem, "", l, r, nil)
 	if err != nil {
 		return nil, err
 	}
-	e.Listener = lr
-	e.Use(echomw.BodyLimit("0"))
+	if e.TLS != nil {
+		if lr != nil {
+			e.TLSListener = tls.NewListener(lr, e.TLS.Config())
+		}
+	} else {
+		e.Listener = lr
+	}
 
 	s := &Server{
 		config:  c,
 		log:     l,
 		srv:     e,
-		handler: h,
+		handler: mh,
+		health:  h,
 	}
 
 	e.GET(c.Path, s.Handle)
+	e.GET(PathHealth, s.HandleHealth(health.KindAll))
+	e.GET(PathLiveness, s.HandleHealth(health.KindLiveness))
+	e.GET(PathReadiness, s.HandleHealth(health.KindReadiness))
 
 	return s, nil
 }
@@ -0,0 +1,77 @@
+package statsd
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+const (
+	FormatStatsD    = "statsd"
+	FormatDogStatsD = "dogstatsd"
+)
+
+// Formats are line formats, statsd has no tags so labels are appended to metric name.
+var Formats = map[string]struct{}{
+	FormatStatsD:    {},
+	FormatDogStatsD: {},
+}
+
+type Config struct {
+	Enable bool   `yaml:"enable"`
+	Addr   string `yaml:"addr"`
+	Format string `yaml:"format"`
+	// Prefix is prepended to metric names (like "myapp.").
+	Prefix string `yaml:"prefix"`
+	// Tags are added to all metrics (dogstatsd format only).
+	Tags     map[string]string `yaml:"tags"`
+	Interval time.Duration     `yaml:"interval"`
+	// MaxPacketSize limits datagram size, default fits into ethernet MTU.
+	MaxPacketSize int `yaml:"max-packet-size"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.Addr == "":
+			c.Addr = "127.0.0.1:8125"
+		case c.Format == "":
+			c.Format = FormatDogStatsD
+		case c.Interval <= 0:
+			c.Interval = 10 * time.Second
+		case c.MaxPacketSize <= 0:
+			c.MaxPacketSize = 1432
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	_, _, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse addr %q", c.Addr)
+	}
+	if _, ok := Formats[c.Format]; !ok {
+		available := make([]string, 0, len(Formats))
+		for k := range Formats {
+			available = append(available, k)
+		}
+		sort.Strings(available)
+
+		return errors.Errorf(
+			"unexpected format %q, expected one of: %q",
+			c.Format, available,
+		)
+	}
+	if len(c.Tags) > 0 && c.Format != FormatDogStatsD {
+		return errors.Errorf("tags are supported only by %q format", FormatDogStatsD)
+	}
+	return nil
+}
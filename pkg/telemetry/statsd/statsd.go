@@ -0,0 +1,204 @@
+package statsd
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/log"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+)
+
+var (
+	nameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+	pathReplacer = strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+)
+
+// Exporter bridges registry into StatsD (or DogStatsD) over UDP.
+// Counters (and histogram, summary counts and sums) are sent as deltas
+// since previous flush, gauges are sent as is.
+type Exporter struct {
+	config Config
+	log    log.Logger
+	reg    *registry.Registry
+	conn   net.Conn
+	tags   string
+	last   map[string]float64
+}
+
+func (e *Exporter) key(name string, labels []*dto.LabelPair) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, l := range labels {
+		b.WriteString("\xff" + l.GetName() + "=" + l.GetValue())
+	}
+	return b.String()
+}
+
+func (e *Exporter) format(name string, labels []*dto.LabelPair, value float64, kind string) string {
+	var b strings.Builder
+
+	b.WriteString(nameReplacer.Replace(e.config.Prefix + name))
+	if e.config.Format == FormatStatsD {
+		for _, l := range labels {
+			b.WriteString("." + pathReplacer.Replace(l.GetName()) + "." + pathReplacer.Replace(l.GetValue()))
+		}
+	}
+	b.WriteString(":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + kind)
+
+	if e.config.Format == FormatDogStatsD {
+		tags := make([]string, 0, len(labels))
+		for _, l := range labels {
+			tags = append(tags, nameReplacer.Replace(l.GetName())+":"+nameReplacer.Replace(l.GetValue()))
+		}
+		if e.tags != "" {
+			tags = append(tags, e.tags)
+		}
+		if len(tags) > 0 {
+			b.WriteString("|#" + strings.Join(tags, ","))
+		}
+	}
+	return b.String()
+}
+
+// counter returns increase since previous flush, counter resets are handled as increase from zero.
+func (e *Exporter) counter(name string, labels []*dto.LabelPair, value float64) (float64, bool) {
+	k := e.key(name, labels)
+	last, ok := e.last[k]
+	e.last[k] = value
+	delta := value - last
+	if ok && delta < 0 {
+		delta = value
+	}
+	return delta, delta != 0
+}
+
+func (e *Exporter) lines(mfs []*dto.MetricFamily) []string {
+	lines := []string{}
+	// NaN and infinite values could not be represented
+	valid := func(value float64) bool { return !math.IsNaN(value) && !math.IsInf(value, 0) }
+
+	counter := func(name string, labels []*dto.LabelPair, value float64) {
+		if !valid(value) {
+			return
+		}
+		if delta, ok := e.counter(name, labels, value); ok {
+			lines = append(lines, e.format(name, labels, delta, "c"))
+		}
+	}
+	gauge := func(name string, labels []*dto.LabelPair, value float64) {
+		if !valid(value) {
+			return
+		}
+		if value < 0 {
+			// signed gauge values are relative changes, so it is reset first
+			lines = append(lines, e.format(name, labels, 0, "g"))
+		}
+		lines = append(lines, e.format(name, labels, value, "g"))
+	}
+
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			labels := m.GetLabel()
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				counter(name, labels, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				gauge(name, labels, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				gauge(name, labels, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				counter(name+"_count", labels, float64(m.GetHistogram().GetSampleCount()))
+				counter(name+"_sum", labels, m.GetHistogram().GetSampleSum())
+			case dto.MetricType_SUMMARY:
+				counter(name+"_count", labels, float64(m.GetSummary().GetSampleCount()))
+				counter(name+"_sum", labels, m.GetSummary().GetSampleSum())
+			}
+		}
+	}
+	return lines
+}
+
+// Flush gathers metrics and sends them packed into datagrams up to MaxPacketSize.
+func (e *Exporter) Flush() error {
+	mfs, err := e.reg.Gather()
+	if err != nil {
+		return errors.Wrap(err, "failed to gather metrics")
+	}
+
+	var buf bytes.Buffer
+	send := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		_, err := e.conn.Write(buf.Bytes())
+		buf.Reset()
+		return err
+	}
+	for _, l := range e.lines(mfs) {
+		if buf.Len() > 0 && buf.Len()+1+len(l) > e.config.MaxPacketSize {
+			err = send()
+			if err != nil {
+				return err
+			}
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(l)
+	}
+	return send()
+}
+
+// Run flushes metrics each interval until done is closed, then flushes them last time.
+func (e *Exporter) Run(done <-chan struct{}) {
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+	defer e.conn.Close()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := e.Flush()
+			if err != nil {
+				e.log.Warn().Err(err).Msg("failed to flush metrics")
+			}
+		case <-done:
+			err := e.Flush()
+			if err != nil {
+				e.log.Error().Err(err).Msg("failed to flush metrics on shutdown")
+			}
+			return
+		}
+	}
+}
+
+func New(c Config, l log.Logger, r *registry.Registry) (*Exporter, error) {
+	conn, err := net.Dial("udp", c.Addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial statsd %q", c.Addr)
+	}
+
+	tags := make([]string, 0, len(c.Tags))
+	for k, v := range c.Tags {
+		tags = append(tags, nameReplacer.Replace(k)+":"+nameReplacer.Replace(v))
+	}
+	sort.Strings(tags)
+
+	return &Exporter{
+		config: c,
+		log:    l.With().Str("component", "statsd").Logger(),
+		reg:    r,
+		conn:   conn,
+		tags:   strings.Join(tags, ","),
+		last:   map[string]float64{},
+	}, nil
+}
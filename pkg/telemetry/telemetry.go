@@ -1,3 +1,9 @@
 package telemetry
 
-const Subsystem = "telemetry"
+const (
+	Subsystem = "telemetry"
+
+	PathHealth    = "/healthz"
+	PathLiveness  = "/livez"
+	PathReadiness = "/readyz"
+)
@@ -0,0 +1,74 @@
+package trace
+
+import (
+	"net/url"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/meta"
+)
+
+type Config struct {
+	Enable bool `yaml:"enable"`
+	// ServiceName is reported as service.name resource attribute.
+	ServiceName string `yaml:"service-name"`
+	// Endpoint is an OTLP/HTTP traces endpoint of the collector.
+	Endpoint string            `yaml:"endpoint"`
+	Headers  map[string]string `yaml:"headers"`
+	Timeout  time.Duration     `yaml:"timeout"`
+	// SampleRatio is a fraction of root traces sampled,
+	// traces started by remote parent follow parent sampling decision,
+	// zero disables sampling of root traces.
+	SampleRatio   *float64      `yaml:"sample-ratio"`
+	BatchSize     int           `yaml:"batch-size"`
+	QueueSize     int           `yaml:"queue-size"`
+	FlushInterval time.Duration `yaml:"flush-interval"`
+}
+
+func (c *Config) Default() {
+loop:
+	for {
+		switch {
+		case c.ServiceName == "":
+			c.ServiceName = meta.Name
+		case c.Endpoint == "":
+			c.Endpoint = "http://127.0.0.1:4318/v1/traces"
+		case c.Timeout <= 0:
+			c.Timeout = 10 * time.Second
+		case c.SampleRatio == nil:
+			ratio := 1.0
+			c.SampleRatio = &ratio
+		case c.BatchSize <= 0:
+			c.BatchSize = 512
+		case c.QueueSize <= 0:
+			c.QueueSize = 2048
+		case c.FlushInterval <= 0:
+			c.FlushInterval = 5 * time.Second
+		default:
+			break loop
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse endpoint %q", c.Endpoint)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("endpoint %q scheme should be http or https", c.Endpoint)
+	}
+	if *c.SampleRatio < 0 || *c.SampleRatio > 1 {
+		return errors.Errorf("sample-ratio should be in range [0, 1], got %v", *c.SampleRatio)
+	}
+	if c.BatchSize > c.QueueSize {
+		return errors.Errorf(
+			"batch-size %d should not be greater than queue-size %d",
+			c.BatchSize, c.QueueSize,
+		)
+	}
+	return nil
+}
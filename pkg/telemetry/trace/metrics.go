@@ -0,0 +1,34 @@
+package trace
+
+import (
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/collector"
+	"git.backbone/corpix/goboilerplate/pkg/telemetry/registry"
+)
+
+const (
+	ResultExported = "exported"
+	ResultFailed   = "failed"
+	ResultDropped  = "dropped"
+)
+
+type Metrics struct {
+	Spans *collector.CounterVec
+}
+
+func (m *Metrics) span(result string, n int) {
+	if m == nil {
+		return
+	}
+	m.Spans.WithLabelValues(result).Add(float64(n))
+}
+
+func NewMetrics(r *registry.Registry, subsystem string) *Metrics {
+	f := registry.NewFactory(r, subsystem)
+	return &Metrics{
+		Spans: f.CounterVec(
+			"trace_spans_total",
+			"How many sampled spans were processed, partitioned by export result.",
+			"result",
+		),
+	}
+}
@@ -0,0 +1,180 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/meta"
+)
+
+// OTLP/HTTP JSON encoding (https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding),
+// ids are hex encoded and 64-bit integers are strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		TraceState        string         `json:"traceState,omitempty"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              SpanKind       `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    StatusCode `json:"code,omitempty"`
+		Message string     `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+func otlpAttributeValue(v interface{}) otlpValue {
+	switch vv := v.(type) {
+	case string:
+		return otlpValue{StringValue: &vv}
+	case bool:
+		return otlpValue{BoolValue: &vv}
+	case int:
+		s := strconv.FormatInt(int64(vv), 10)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(vv, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &vv}
+	default:
+		s := fmt.Sprint(vv)
+		return otlpValue{StringValue: &s}
+	}
+}
+
+func otlpAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(attributes))
+	for k, v := range attributes {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: otlpAttributeValue(v)})
+	}
+	return kvs
+}
+
+func otlpEncodeSpan(s *Span) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := otlpSpan{
+		TraceID:           s.context.TraceID.String(),
+		SpanID:            s.context.SpanID.String(),
+		TraceState:        s.context.State,
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes:        otlpAttributes(s.attributes),
+		Status:            otlpStatus{Code: s.status, Message: s.message},
+	}
+	if s.parent.IsValid() {
+		span.ParentSpanID = s.parent.String()
+	}
+	return span
+}
+
+//
+
+// OTLPExporter exports spans to OpenTelemetry collector with OTLP/HTTP JSON encoding.
+type OTLPExporter struct {
+	config   Config
+	client   *http.Client
+	resource otlpResource
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, spans []*Span) error {
+	encoded := make([]otlpSpan, len(spans))
+	for n, s := range spans {
+		encoded[n] = otlpEncodeSpan(s)
+	}
+
+	buf, err := json.Marshal(otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: e.resource,
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: meta.Name, Version: meta.Version},
+				Spans: encoded,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return errors.Errorf(
+			"collector responded with status %d: %s",
+			res.StatusCode, bytes.TrimSpace(body),
+		)
+	}
+	_, _ = io.Copy(ioutil.Discard, res.Body) // keep connection reusable
+	return nil
+}
+
+func NewOTLPExporter(c Config) *OTLPExporter {
+	return &OTLPExporter{
+		config: c,
+		client: &http.Client{Timeout: c.Timeout},
+		resource: otlpResource{
+			Attributes: otlpAttributes(map[string]interface{}{
+				"service.name":    c.ServiceName,
+				"service.version": meta.Version,
+			}),
+		},
+	}
+}
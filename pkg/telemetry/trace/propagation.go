@@ -0,0 +1,156 @@
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+)
+
+// W3C trace context (https://www.w3.org/TR/trace-context/) headers.
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+
+	traceparentVersion = "00"
+	// maxTracestateLength limits propagated tracestate,
+	// longer values are dropped as allowed by specification.
+	maxTracestateLength = 512
+)
+
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses traceparent header value:
+//
+//	version "-" trace-id "-" parent-id "-" trace-flags
+//
+// Future versions are accepted when their prefix is valid version 00 value.
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	value = strings.TrimSpace(value)
+	if len(value) < 55 {
+		return sc, ErrInvalidTraceparent
+	}
+	version := value[:2]
+	if !isLowerHex(version) || version == "ff" {
+		return sc, ErrInvalidTraceparent
+	}
+	if version == traceparentVersion && len(value) != 55 {
+		return sc, ErrInvalidTraceparent
+	}
+	if len(value) > 55 && value[55] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+
+	var (
+		traceID = value[3:35]
+		spanID  = value[36:52]
+		flags   = value[53:55]
+	)
+	if !isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) {
+		return sc, ErrInvalidTraceparent
+	}
+
+	_, _ = hex.Decode(sc.TraceID[:], []byte(traceID))
+	_, _ = hex.Decode(sc.SpanID[:], []byte(spanID))
+	var f [1]byte
+	_, _ = hex.Decode(f[:], []byte(flags))
+	sc.Flags = TraceFlags(f[0]) & FlagsSampled // other flags are not known for version 00
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Traceparent formats span context as traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return traceparentVersion + "-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + sc.Flags.String()
+}
+
+//
+
+// Extract reads span context from traceparent and tracestate headers,
+// tracestate is ignored without valid traceparent.
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(h.Get(HeaderTraceparent))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	state := strings.Join(h.Values(HeaderTracestate), ",")
+	if len(state) <= maxTracestateLength {
+		sc.State = state
+	}
+	sc.Remote = true
+	return sc, true
+}
+
+// Inject writes span context into traceparent and tracestate headers.
+func Inject(h http.Header, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(HeaderTraceparent, sc.Traceparent())
+	if sc.State != "" {
+		h.Set(HeaderTracestate, sc.State)
+	} else {
+		h.Del(HeaderTracestate)
+	}
+}
+
+// InjectContext writes span context of ctx into headers,
+// use it for outgoing requests.
+func InjectContext(ctx context.Context, h http.Header) {
+	Inject(h, SpanContextFromContext(ctx))
+}
+
+//
+
+// Transport is an http.RoundTripper which starts client span for each request
+// and propagates it to the server with trace context headers.
+type Transport struct {
+	Tracer *Tracer
+	Base   http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := t.Tracer.Start(req.Context(), req.Method, SpanKindClient)
+	defer span.End()
+
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.Redacted())
+
+	req = req.Clone(ctx) // round trippers should not modify request
+	InjectContext(ctx, req.Header)
+
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", res.StatusCode)
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(StatusError, res.Status)
+	}
+	return res, nil
+}
@@ -0,0 +1,173 @@
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type (
+	TraceID    [16]byte
+	SpanID     [8]byte
+	TraceFlags byte
+
+	// SpanContext identifies span and is propagated across process boundaries.
+	SpanContext struct {
+		TraceID TraceID
+		SpanID  SpanID
+		Flags   TraceFlags
+		// State is a raw tracestate header value, propagated as is.
+		State string
+		// Remote is set for span contexts extracted from requests.
+		Remote bool
+	}
+
+	// SpanKind values match OTLP span kinds.
+	SpanKind   int
+	StatusCode int
+
+	Span struct {
+		tracer *Tracer
+		mu     sync.Mutex
+
+		context    SpanContext
+		parent     SpanID
+		name       string
+		kind       SpanKind
+		start      time.Time
+		end        time.Time
+		attributes map[string]interface{}
+		status     StatusCode
+		message    string
+	}
+
+	contextKey struct{ name string }
+)
+
+const (
+	FlagsSampled TraceFlags = 0x01
+
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+var (
+	spanContextKey   = &contextKey{"span"}
+	remoteContextKey = &contextKey{"remote-span-context"}
+)
+
+func (id TraceID) IsValid() bool  { return id != TraceID{} }
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) IsValid() bool  { return id != SpanID{} }
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+func (f TraceFlags) IsSampled() bool { return f&FlagsSampled != 0 }
+func (f TraceFlags) String() string  { return fmt.Sprintf("%02x", byte(f)) }
+
+func (sc SpanContext) IsValid() bool   { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+func (sc SpanContext) IsSampled() bool { return sc.Flags.IsSampled() }
+
+//
+
+// Span methods are safe to call on nil span, so code could be instrumented
+// regardless of tracing being enabled.
+
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttribute sets span attribute, values are strings, bools, integers or floats,
+// other values are formatted as strings.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attributes[key] = value
+	s.mu.Unlock()
+}
+
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.status = code
+	s.message = message
+	s.mu.Unlock()
+}
+
+// RecordError marks span as failed with error message.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End finishes span and queues it for export when it is sampled,
+// calls after first one are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.context.IsSampled() {
+		s.tracer.enqueue(s)
+	}
+}
+
+//
+
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey, s)
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanContextKey).(*Span)
+	return s
+}
+
+// ContextWithRemoteSpanContext stores span context extracted from request,
+// it becomes a parent of the span started next.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteContextKey, sc)
+}
+
+// SpanContextFromContext returns context of the current span,
+// or remote span context when no span was started.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.Context()
+	}
+	sc, _ := ctx.Value(remoteContextKey).(SpanContext)
+	return sc
+}
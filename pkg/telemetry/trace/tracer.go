@@ -0,0 +1,225 @@
+package trace
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"git.backbone/corpix/goboilerplate/pkg/crypto"
+	"git.backbone/corpix/goboilerplate/pkg/errors"
+	"git.backbone/corpix/goboilerplate/pkg/log"
+)
+
+type (
+	// Exporter sends finished spans to the tracing backend.
+	Exporter interface {
+		Export(ctx context.Context, spans []*Span) error
+	}
+
+	Tracer struct {
+		config   Config
+		log      log.Logger
+		rand     crypto.Rand
+		exporter Exporter
+		metrics  *Metrics
+
+		// threshold is a sampling boundary for trace id (like OpenTelemetry TraceIDRatioBased).
+		threshold uint64
+		queue     chan *Span
+		done      chan struct{}
+		stopped   chan struct{}
+		once      sync.Once
+	}
+
+	Option = func(*Tracer)
+)
+
+// WithExporter replaces OTLP exporter.
+func WithExporter(e Exporter) Option {
+	return func(t *Tracer) {
+		t.exporter = e
+	}
+}
+
+func WithMetrics(m *Metrics) Option {
+	return func(t *Tracer) {
+		t.metrics = m
+	}
+}
+
+//
+
+// Start starts span which is a child of the span (or remote span context) from ctx,
+// returned context carries started span.
+// Nil tracer starts no spans, so it could be used when tracing is disabled.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	var (
+		parent = SpanContextFromContext(ctx)
+		sc     SpanContext
+	)
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Flags = parent.Flags
+		sc.State = parent.State
+	} else {
+		sc.TraceID = t.traceID()
+		if t.sample(sc.TraceID) {
+			sc.Flags |= FlagsSampled
+		}
+	}
+	sc.SpanID = t.spanID()
+
+	s := &Span{
+		tracer:     t,
+		context:    sc,
+		parent:     parent.SpanID,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: map[string]interface{}{},
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+func (t *Tracer) sample(id TraceID) bool {
+	return binary.BigEndian.Uint64(id[8:16])>>1 < t.threshold
+}
+
+func (t *Tracer) traceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		t.read(id[:])
+	}
+	return id
+}
+
+func (t *Tracer) spanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		t.read(id[:])
+	}
+	return id
+}
+
+func (t *Tracer) read(buf []byte) {
+	_, err := io.ReadFull(t.rand, buf)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to generate trace id"))
+	}
+}
+
+//
+
+// enqueue never blocks request processing, spans are dropped when queue is full.
+func (t *Tracer) enqueue(s *Span) {
+	select {
+	case <-t.done:
+		t.metrics.span(ResultDropped, 1)
+		return
+	default:
+	}
+	select {
+	case t.queue <- s:
+	default:
+		t.metrics.span(ResultDropped, 1)
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.stopped)
+
+	var (
+		ticker = time.NewTicker(t.config.FlushInterval)
+		batch  = make([]*Span, 0, t.config.BatchSize)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) >= t.config.BatchSize {
+				batch = t.export(batch)
+			}
+		case <-ticker.C:
+			batch = t.export(batch)
+		case <-t.done:
+			for {
+				select {
+				case s := <-t.queue:
+					batch = append(batch, s)
+					if len(batch) >= t.config.BatchSize {
+						batch = t.export(batch)
+					}
+				default:
+					t.export(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *Tracer) export(batch []*Span) []*Span {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.config.Timeout)
+	defer cancel()
+
+	err := t.exporter.Export(ctx, batch)
+	if err != nil {
+		t.metrics.span(ResultFailed, len(batch))
+		t.log.Warn().Err(err).Int("spans", len(batch)).Msg("failed to export spans")
+	} else {
+		t.metrics.span(ResultExported, len(batch))
+	}
+	return batch[:0]
+}
+
+// Shutdown exports queued spans and stops exporting,
+// spans ended after shutdown are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.once.Do(func() { close(t.done) })
+
+	select {
+	case <-t.stopped:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "failed to flush spans")
+	}
+}
+
+// New creates tracer which exports spans in background until Shutdown.
+func New(c Config, l log.Logger, rand crypto.Rand, options ...Option) *Tracer {
+	t := &Tracer{
+		config:    c,
+		log:       l.With().Str("component", "trace").Logger(),
+		rand:      rand,
+		threshold: uint64(*c.SampleRatio * (math.MaxUint64 >> 1)),
+		queue:     make(chan *Span, c.QueueSize),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	for _, option := range options {
+		option(t)
+	}
+	if t.exporter == nil {
+		t.exporter = NewOTLPExporter(c)
+	}
+
+	go t.run()
+
+	return t
+}
@@ -0,0 +1,87 @@
+// Command otlp is a local OpenTelemetry collector stand-in,
+// it accepts OTLP/HTTP JSON traces and prints received spans to stdout.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type request struct {
+	ResourceSpans []struct {
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID           string `json:"traceId"`
+				SpanID            string `json:"spanId"`
+				ParentSpanID      string `json:"parentSpanId"`
+				Name              string `json:"name"`
+				Kind              int    `json:"kind"`
+				StartTimeUnixNano string `json:"startTimeUnixNano"`
+				EndTimeUnixNano   string `json:"endTimeUnixNano"`
+				Attributes        []struct {
+					Key   string                 `json:"key"`
+					Value map[string]interface{} `json:"value"`
+				} `json:"attributes"`
+				Status struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+func duration(start string, end string) time.Duration {
+	s, _ := strconv.ParseInt(start, 10, 64)
+	e, _ := strconv.ParseInt(end, 10, 64)
+	return time.Duration(e - s)
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req request
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				attributes := map[string]interface{}{}
+				for _, kv := range s.Attributes {
+					for _, v := range kv.Value {
+						attributes[kv.Key] = v
+					}
+				}
+				fmt.Printf(
+					"trace=%s span=%s parent=%s kind=%d status=%d duration=%s name=%q attributes=%v\n",
+					s.TraceID, s.SpanID, s.ParentSpanID, s.Kind, s.Status.Code,
+					duration(s.StartTimeUnixNano, s.EndTimeUnixNano), s.Name, attributes,
+				)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte("{}"))
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:4318", "address to listen on")
+	flag.Parse()
+
+	http.HandleFunc("/v1/traces", handle)
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}